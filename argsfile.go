@@ -0,0 +1,125 @@
+// Copyright 2020 Vedran Vuk. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package flagex
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+// SetArgsFiles sets if args of the form "@path" are replaced with args read
+// from the file at path before parsing. Expansion applies to these Flags
+// and all of their subs.
+//
+// Args in an args file are separated by whitespace and may be quoted with
+// single or double quotes or escaped with a backslash as in a POSIX shell.
+// A "#" at the start of an arg starts a comment that ends at the end of the
+// line. An unquoted "@path" in an args file includes another args file
+// whose path, if relative, is relative to the directory of the including
+// file. Including a file that is already being included is an error.
+// Args following the terminator are not expanded.
+func (f *Flags) SetArgsFiles(enabled bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.argsfiles = enabled
+}
+
+// ArgsFiles returns if args files are expanded when parsing.
+func (f *Flags) ArgsFiles() bool {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.argsfiles
+}
+
+// isargsfile returns if arg names an args file.
+func isargsfile(arg string) bool {
+	return len(arg) > 1 && arg[0] == '@'
+}
+
+// expandargs returns args with args naming args files replaced with args
+// read from those files along with indexes of args in args they came from.
+// Args following terminator are not expanded.
+func expandargs(args []string, terminator string) ([]string, []int, error) {
+	e := &expander{terminator: terminator}
+	for i, arg := range args {
+		e.index = i
+		if trimmed := strings.TrimSpace(arg); !e.done && isargsfile(trimmed) {
+			if err := e.include(trimmed[1:], "", "argv", i); err != nil {
+				return nil, nil, err
+			}
+			continue
+		}
+		e.add(arg)
+	}
+	return e.out, e.idx, nil
+}
+
+// expander expands args files.
+type expander struct {
+	terminator string
+	stack      []string
+	out        []string
+	idx        []int
+	index      int
+	done       bool
+}
+
+// add adds arg to expanded args.
+func (e *expander) add(arg string) {
+	e.out = append(e.out, arg)
+	e.idx = append(e.idx, e.index)
+	if e.terminator != "" && strings.TrimSpace(arg) == e.terminator {
+		e.done = true
+	}
+}
+
+// include expands args file under name referenced at line in file from.
+// If name is relative it is relative to dir.
+func (e *expander) include(name, dir, from string, line int) error {
+	path := name
+	if dir != "" && !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return ErrArgsFile.WrapCauseArgs(err, from, line, fmt.Sprintf("cannot read args file '%s'", name))
+	}
+	for _, v := range e.stack {
+		if v == abs {
+			return ErrArgsFile.WrapArgs(from, line, fmt.Sprintf("args file '%s' includes itself", name))
+		}
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return ErrArgsFile.WrapCauseArgs(err, from, line, fmt.Sprintf("cannot read args file '%s'", name))
+	}
+	tokens, err := splitargsfile(path, string(data))
+	if err != nil {
+		return err
+	}
+	e.stack = append(e.stack, abs)
+	for _, token := range tokens {
+		if !e.done && !token.Quoted && isargsfile(token.Text) {
+			if err := e.include(token.Text[1:], filepath.Dir(path), path, token.Line); err != nil {
+				return err
+			}
+			continue
+		}
+		e.add(token.Text)
+	}
+	e.stack = e.stack[:len(e.stack)-1]
+	return nil
+}
+
+// splitargsfile splits text of args file at path into args.
+func splitargsfile(path, text string) ([]Token, error) {
+	tokens, serr := Tokenizer{}.tokens(text)
+	if serr != nil {
+		return nil, ErrArgsFile.WrapArgs(path, serr.line, serr.msg)
+	}
+	return tokens, nil
+}
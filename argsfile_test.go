@@ -0,0 +1,119 @@
+// Copyright 2020 Vedran Vuk. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package flagex
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeargsfiles(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "flagex")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, text := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(text), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestArgsFiles(t *testing.T) {
+	dir := writeargsfiles(t, map[string]string{
+		"main.args":   "# build args\n-v --output \"out file\" 'it''s' \\\n  @inc.args # trailing\n'@literal'\n",
+		"inc.args":    "--include a -I \"b\\\"c\"\n",
+		"cycle.args":  "-v\n@cycle2.args\n",
+		"cycle2.args": "\n\n@cycle.args\n",
+		"quote.args":  "-v\n--output 'out\n",
+		"term.args":   "-v -- @inc.args\n",
+	})
+	defer os.RemoveAll(dir)
+	path := func(name string) string { return "@" + filepath.Join(dir, name) }
+
+	f := New()
+	f.DefineSwitch("verbose", "v", "verbose")
+	f.DefineOptional("output", "o", "output", "file", "")
+	f.DefineOptional("include", "I", "include", "path", "")
+	inc, _ := f.GetKey("include")
+	inc.SetRepeatable(true)
+
+	if err := f.Parse([]string{path("main.args")}); err != nil {
+		t.Fatal(err)
+	}
+	if len(f.Args()) != 1 || f.Args()[0] != path("main.args") {
+		t.Fatalf("expanded while disabled: %v", f.Args())
+	}
+
+	f.SetArgsFiles(true)
+	if err := f.Parse([]string{path("main.args"), "last"}); err != nil {
+		t.Fatal(err)
+	}
+	if !f.Parsed("verbose") || f.GetValue("output") != "out file" {
+		t.Fatalf("expand failed: %v", f.ParseMap())
+	}
+	if v := fmt.Sprint(inc.Values()); v != `[a b"c]` {
+		t.Fatalf("include failed: %s", v)
+	}
+	if v := strings.Join(f.Args(), ","); v != "its,@literal,last" {
+		t.Fatalf("operands failed: %s", v)
+	}
+
+	if err := f.Parse([]string{path("term.args")}); err != nil {
+		t.Fatal(err)
+	}
+	if v := strings.Join(f.Args(), ","); v != "@inc.args" {
+		t.Fatalf("terminator failed: %s", v)
+	}
+	if err := f.Parse([]string{"-v", "--", path("inc.args")}); err != nil {
+		t.Fatal(err)
+	}
+	if v := strings.Join(f.Args(), ","); v != path("inc.args") {
+		t.Fatalf("terminator failed: %s", v)
+	}
+
+	type Test struct {
+		Args     []string
+		Position string
+	}
+	tests := []Test{
+		{[]string{path("cycle.args")}, filepath.Join(dir, "cycle2.args") + ":3:"},
+		{[]string{path("quote.args")}, filepath.Join(dir, "quote.args") + ":2:"},
+		{[]string{"-v", path("missing.args")}, "argv:1:"},
+	}
+	for _, test := range tests {
+		err := f.Parse(test.Args)
+		if !errors.Is(err, ErrArgsFile) || !strings.Contains(err.Error(), test.Position) {
+			t.Fatalf("%v: expected error at '%s', got '%v'", test.Args, test.Position, err)
+		}
+	}
+}
+
+func TestArgsFilesSub(t *testing.T) {
+	dir := writeargsfiles(t, map[string]string{
+		"sub.args": "--addr :8080 www",
+	})
+	defer os.RemoveAll(dir)
+
+	sub := New()
+	sub.DefineOptional("addr", "a", "listen address", "address", "")
+	sub.SetArgsFiles(true)
+	f := New()
+	f.DefineSwitch("verbose", "v", "verbose")
+	f.DefineSub("serve", "s", "serve", sub)
+
+	if err := f.Parse([]string{"-v", "-s", "@" + filepath.Join(dir, "sub.args")}); err != nil {
+		t.Fatal(err)
+	}
+	if sub.GetValue("addr") != ":8080" || len(sub.Args()) != 1 || sub.Args()[0] != "www" {
+		t.Fatalf("sub expand failed: %v %v", sub.ParseMap(), sub.Args())
+	}
+}
@@ -0,0 +1,98 @@
+// Copyright 2020 Vedran Vuk. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package flagex
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Choice is a value allowed for a Flag with an optional help text.
+type Choice struct {
+	// Value is the allowed value.
+	Value string
+	// Help is an optional help text of the value.
+	Help string
+}
+
+// SetChoices restricts flag values to specified choices. Parsing any other
+// value from args, environment or config returns ErrChoice. If fold is true,
+// values are matched case insensitively and stored as given in choices.
+// If flag has a separator set, each separated value must be a choice.
+// Choices do not apply to switches, counters, subs and operations and are
+// removed if none are specified.
+//
+// Choices are listed in help, with their help texts if any, and are
+// returned by Choices for use by shell completion.
+func (f *Flag) SetChoices(fold bool, choices ...Choice) {
+	f.flags.mu.Lock()
+	defer f.flags.mu.Unlock()
+	f.choices = append([]Choice(nil), choices...)
+	f.fold = fold
+}
+
+// Choices returns values allowed for flag or nil if any value is allowed.
+func (f *Flag) Choices() []Choice {
+	f.flags.mu.RLock()
+	defer f.flags.mu.RUnlock()
+	return append([]Choice(nil), f.choices...)
+}
+
+// FoldChoices returns if flag choices are matched case insensitively.
+func (f *Flag) FoldChoices() bool {
+	f.flags.mu.RLock()
+	defer f.flags.mu.RUnlock()
+	return f.fold
+}
+
+// haschoices returns if Flag has choices that apply to it.
+func (f *Flag) haschoices() bool {
+	return len(f.choices) > 0 && f.sub == nil && !f.isswitch()
+}
+
+// choose returns value as given in Flag choices and true if it is one of
+// them. If no choices are set, value is returned as is.
+func (f *Flag) choose(value string) (string, bool) {
+	if len(f.choices) == 0 {
+		return value, true
+	}
+	for _, c := range f.choices {
+		if c.Value == value || f.fold && strings.EqualFold(c.Value, value) {
+			return c.Value, true
+		}
+	}
+	return "", false
+}
+
+// choicelist returns Flag choice values separated by sep.
+func (f *Flag) choicelist(sep string) string {
+	values := make([]string, 0, len(f.choices))
+	for _, c := range f.choices {
+		values = append(values, c.Value)
+	}
+	return strings.Join(values, sep)
+}
+
+// choicehelp returns if any of Flag choices has a help text.
+func (f *Flag) choicehelp() bool {
+	if !f.haschoices() {
+		return false
+	}
+	for _, c := range f.choices {
+		if c.Help != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// printchoices prints Flag choices with help texts to w, one per line,
+// indented with indent.
+func (f *Flag) printchoices(w io.Writer, indent string) {
+	for _, c := range f.choices {
+		fmt.Fprintf(w, "%s\t  %s\t%s\t\n", indent, c.Value, c.Help)
+	}
+}
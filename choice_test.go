@@ -0,0 +1,69 @@
+// Copyright 2020 Vedran Vuk. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package flagex
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestChoices(t *testing.T) {
+	f := New()
+	f.DefineOptional("tlsmode", "t", "tls mode", "", "v3")
+	f.DefineOptional("color", "c", "color output", "when", "auto")
+	f.DefineOptional("proto", "p", "protocols", "", "")
+	f.DefineSwitch("verbose", "v", "verbose")
+	tls, _ := f.GetKey("tlsmode")
+	tls.SetChoices(false, Choice{Value: "v1"}, Choice{Value: "v2"}, Choice{Value: "v3"})
+	color, _ := f.GetKey("color")
+	color.SetChoices(true, Choice{"auto", "detect terminal"}, Choice{"always", "always color"}, Choice{"never", "never color"})
+	proto, _ := f.GetKey("proto")
+	proto.SetChoices(false, Choice{Value: "tcp"}, Choice{Value: "udp"})
+	proto.SetSeparator(",")
+	verbose, _ := f.GetKey("verbose")
+	verbose.SetChoices(false, Choice{Value: "x"})
+
+	if err := f.Parse([]string{"-t", "v2", "--color=ALWAYS", "-p", "udp,tcp", "-v"}); err != nil {
+		t.Fatal(err)
+	}
+	if tls.Value() != "v2" || color.Value() != "always" || strings.Join(proto.Values(), " ") != "udp tcp" {
+		t.Fatalf("choices failed: %v", f.ParseMap())
+	}
+
+	type Test struct {
+		Args    string
+		Message string
+	}
+	tests := []Test{
+		{"-t V2", "invalid value 'V2' for key 'tlsmode', choose one of: v1, v2, v3"},
+		{"--color sometimes", "choose one of: auto, always, never"},
+		{"-p tcp,sctp", "invalid value 'sctp' for key 'proto'"},
+	}
+	for _, test := range tests {
+		err := f.Parse(strings.Split(test.Args, " "))
+		if !errors.Is(err, ErrChoice) || !strings.Contains(err.Error(), test.Message) {
+			t.Fatalf("'%s': expected '%s', got '%v'", test.Args, test.Message, err)
+		}
+	}
+	defer setenv(t, map[string]string{"FLAGEXCHOICE_TLSMODE": "v4"})()
+	f.SetEnvPrefix("FLAGEXCHOICE")
+	if err := f.Parse([]string{"-v"}); !errors.Is(err, ErrChoice) {
+		t.Fatal(err)
+	}
+
+	s := f.String()
+	for _, v := range []string{"--tlsmode <v1|v2|v3>", "tls mode [choices: v1, v2, v3]", "--color <when>", "     always ", "always color"} {
+		if !strings.Contains(s, v) {
+			t.Fatalf("'%s' not in '%s'", v, s)
+		}
+	}
+	if strings.Contains(s, "[choices: auto") || strings.Contains(s, "[choices: x]") {
+		t.Fatalf("unexpected choices in '%s'", s)
+	}
+	if c := color.Choices(); len(c) != 3 || c[2].Help != "never color" || !color.FoldChoices() {
+		t.Fatalf("choices accessor failed: %v", c)
+	}
+}
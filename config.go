@@ -0,0 +1,258 @@
+// Copyright 2020 Vedran Vuk. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package flagex
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+)
+
+// Decoder decodes a config file into a map of flag keys to values.
+//
+// A value may be a string, a boolean, a number, a slice of those for
+// repeatable flags or a map of the same form for a sub or an operation.
+// A nil value is treated as not set.
+type Decoder interface {
+	Decode(r io.Reader) (map[string]interface{}, error)
+}
+
+// DecoderFunc is a function that implements Decoder.
+type DecoderFunc func(r io.Reader) (map[string]interface{}, error)
+
+// Decode implements Decoder on DecoderFunc.
+func (df DecoderFunc) Decode(r io.Reader) (map[string]interface{}, error) {
+	return df(r)
+}
+
+// JSONDecoder decodes JSON config files whose root is an object.
+var JSONDecoder Decoder = DecoderFunc(func(r io.Reader) (map[string]interface{}, error) {
+	config := make(map[string]interface{})
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	if err := dec.Decode(&config); err != nil {
+		return nil, err
+	}
+	return config, nil
+})
+
+// Config specifies how Flags locate and decode a config file.
+type Config struct {
+	// Key is the key of a flag whose value is the config file path.
+	// If the flag is parsed from args or environment the file must exist,
+	// if its default value is used the file is optional.
+	Key string
+	// Name is the path of an optional config file relative to XDG config
+	// directories, e.g. "app/config.json", searched in order of preference
+	// if no config file path was given by the flag under Key.
+	Name string
+	// Decoder decodes the config file. If nil, JSONDecoder is used.
+	Decoder Decoder
+}
+
+// SetConfig sets how a config file is located and decoded when parsing.
+//
+// Flags not parsed from args or environment take values from the config
+// file, if found, so values are taken from, in order of precedence, args,
+// environment, config file and flag defaults. Required flags may be given
+// by any of those but defaults. Nested maps in the config file configure
+// subs and operations under their keys and apply if they are parsed.
+// Config is only used by the Flags being parsed and not by their subs.
+func (f *Flags) SetConfig(config Config) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.config = config
+}
+
+// Config returns how Flags locate and decode a config file.
+func (f *Flags) Config() Config {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.config
+}
+
+// xdgconfigdirs returns XDG config directories in order of preference.
+func xdgconfigdirs() []string {
+	var dirs []string
+	home := os.Getenv("XDG_CONFIG_HOME")
+	if home == "" {
+		if dir, err := os.UserHomeDir(); err == nil {
+			home = filepath.Join(dir, ".config")
+		}
+	}
+	if home != "" {
+		dirs = append(dirs, home)
+	}
+	paths := os.Getenv("XDG_CONFIG_DIRS")
+	if paths == "" {
+		paths = "/etc/xdg"
+	}
+	for _, dir := range filepath.SplitList(paths) {
+		if dir != "" {
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs
+}
+
+// configpath returns path of the config file and if it must exist.
+// It returns an empty path if no config file was found.
+func (r *Result) configpath() (string, bool) {
+	config := r.flags.config
+	if flag, ok := r.flags.getkey(config.Key); ok && config.Key != "" {
+		if s := r.get(flag); s.parsed && s.value != "" {
+			return s.value, true
+		}
+		if flag.defval != "" {
+			return flag.defvalue(), false
+		}
+	}
+	if config.Name == "" {
+		return "", false
+	}
+	for _, dir := range xdgconfigdirs() {
+		path := filepath.Join(dir, config.Name)
+		if _, err := os.Stat(path); err == nil {
+			return path, true
+		}
+	}
+	return "", false
+}
+
+// loadconfig loads config values from the config file, if any, and returns
+// them along with the config file path.
+func (r *Result) loadconfig() (map[string]interface{}, string, error) {
+	path, required := r.configpath()
+	if path == "" {
+		return nil, "", nil
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		if !required && os.IsNotExist(err) {
+			return nil, "", nil
+		}
+		return nil, "", ErrConfig.WrapCauseArgs(err, path, "cannot read")
+	}
+	defer file.Close()
+	decoder := r.flags.config.Decoder
+	if decoder == nil {
+		decoder = JSONDecoder
+	}
+	config, err := decoder.Decode(file)
+	if err != nil {
+		return nil, "", ErrConfig.WrapCauseArgs(err, path, "cannot decode")
+	}
+	return config, path, nil
+}
+
+// configmap returns v as a config map and truth if v is a map.
+func configmap(v interface{}) (map[string]interface{}, bool) {
+	switch m := v.(type) {
+	case map[string]interface{}:
+		return m, true
+	case map[interface{}]interface{}:
+		config := make(map[string]interface{}, len(m))
+		for k, v := range m {
+			config[fmt.Sprint(k)] = v
+		}
+		return config, true
+	}
+	return nil, false
+}
+
+// configstring returns config value v as a string and truth if v is a
+// string, a boolean or a number.
+func configstring(v interface{}) (string, bool) {
+	switch s := v.(type) {
+	case string:
+		return s, true
+	case bool:
+		return strconv.FormatBool(s), true
+	case float64:
+		return strconv.FormatFloat(s, 'f', -1, 64), true
+	case json.Number:
+		return s.String(), true
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32:
+		return fmt.Sprint(s), true
+	}
+	return "", false
+}
+
+// root returns the root Result.
+func (r *Result) root() *Result {
+	for r.parent != nil {
+		r = r.parent
+	}
+	return r
+}
+
+// configkey returns key of a config value of Result including keys of
+// parent subs separated by dots.
+func (r *Result) configkey(key string) string {
+	for p := r.parent; p != nil; p = p.parent {
+		key = p.subflag.key + "." + key
+	}
+	return key
+}
+
+// parseconfig consumes config values of flags of the Result that were not
+// parsed from args or environment. It returns config values of the sub or
+// operation parsed in Result, if any.
+func (r *Result) parseconfig(config map[string]interface{}) (map[string]interface{}, error) {
+	keys := make([]string, 0, len(config))
+	for key := range config {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var sub map[string]interface{}
+	for _, key := range keys {
+		value := config[key]
+		flag, ok := r.flags.getkey(key)
+		if !ok {
+			if err := r.fail(r.flagerror(ErrConfigKey.WrapArgs(r.configkey(key)), nil)); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if flag.sub != nil {
+			m, ok := configmap(value)
+			if !ok && value != nil {
+				if err := r.fail(r.flagerror(ErrConfigKey.WrapArgs(r.configkey(key)), flag)); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			if flag == r.subflag {
+				sub = m
+			}
+			continue
+		}
+		if value == nil || r.get(flag).parsed || r.excluded(flag) {
+			continue
+		}
+		values, ok := value.([]interface{})
+		if !ok {
+			values = []interface{}{value}
+		}
+		for _, v := range values {
+			s, ok := configstring(v)
+			var err error
+			if ok {
+				ok, err = r.consumefallback(flag, s, Source{Kind: SourceConfig, Name: r.root().configfile, Index: -1})
+			}
+			if err == nil && !ok {
+				err = r.flagerror(ErrConfigKey.WrapArgs(r.configkey(key)), flag)
+			}
+			if err = r.fail(err); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return sub, nil
+}
@@ -0,0 +1,161 @@
+// Copyright 2020 Vedran Vuk. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package flagex
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestConfig(t *testing.T) {
+	dir := writeargsfiles(t, map[string]string{
+		"config.json": `{"verbose": true, "level": 2, "output": "cfg.out", "include": ["a", "b"], "srvparams": {"addr": ":9090"}}`,
+		"badkey.json": `{"srvparams": {"nope": 1}}`,
+		"badval.json": `{"verbose": "maybe"}`,
+		"bad.json":    `{"verbose": `,
+	})
+	defer os.RemoveAll(dir)
+	path := func(name string) string { return filepath.Join(dir, name) }
+	sub := New()
+	sub.DefineOptional("addr", "a", "listen address", "address", ":80")
+	sub.DefineOptional("tls", "t", "tls mode", "mode", "v3")
+	f := New()
+	f.DefineOptional("config", "c", "config file", "filename", "")
+	f.DefineSwitch("verbose", "v", "verbose")
+	f.DefineCounter("level", "l", "level")
+	f.DefineRequired("output", "o", "output", "filename", "")
+	f.DefineOptional("include", "I", "include", "path", "")
+	f.DefineSub("srvparams", "s", "server params", sub)
+	inc, _ := f.GetKey("include")
+	inc.SetRepeatable(true)
+	f.SetConfig(Config{Key: "config"})
+	f.SetEnvPrefix("FLAGEXCFG")
+
+	if err := f.Parse([]string{"-c", path("config.json")}); err != nil {
+		t.Fatal(err)
+	}
+	if f.GetValue("output") != "cfg.out" || f.GetValue("level") != "2" || !f.Parsed("verbose") {
+		t.Fatalf("config failed: %v", f.ParseMap())
+	}
+	if v := fmt.Sprint(f.ParseMap()["include"]); v != "[a b]" {
+		t.Fatalf("config slice failed: %s", v)
+	}
+	if f.Parsed("srvparams") || sub.Parsed("addr") {
+		t.Fatal("config parsed sub")
+	}
+
+	defer setenv(t, map[string]string{"FLAGEXCFG_OUTPUT": "env.out"})()
+	if err := f.Parse([]string{"-c", path("config.json"), "-s", "-t", "v2"}); err != nil {
+		t.Fatal(err)
+	}
+	if f.GetValue("output") != "env.out" {
+		t.Fatal("env does not override config")
+	}
+	if sub.GetValue("addr") != ":9090" || sub.GetValue("tls") != "v2" {
+		t.Fatalf("sub config failed: %v", sub.ParseMap())
+	}
+	if err := f.Parse([]string{"-c", path("config.json"), "--output", "argv.out"}); err != nil {
+		t.Fatal(err)
+	}
+	if f.GetValue("output") != "argv.out" {
+		t.Fatal("args do not override env")
+	}
+	os.Unsetenv("FLAGEXCFG_OUTPUT")
+
+	type Test struct {
+		Args     string
+		Expected error
+		Message  string
+	}
+	tests := []Test{
+		{"-v", ErrRequired, ""},
+		{"-c " + path("missing.json"), ErrConfig, "missing.json"},
+		{"-c " + path("bad.json"), ErrConfig, "bad.json"},
+		{"-c " + path("badval.json") + " -o x", ErrConfigKey, "'verbose'"},
+		{"-c " + path("badkey.json") + " -o x -s -a x", ErrConfigKey, "'srvparams.nope'"},
+	}
+	for _, test := range tests {
+		err := f.Parse(strings.Split(test.Args, " "))
+		if !errors.Is(err, test.Expected) || !strings.Contains(fmt.Sprint(err), test.Message) {
+			t.Fatalf("'%s': expected '%v', got '%v'", test.Args, test.Expected, err)
+		}
+	}
+
+	config, _ := f.GetKey("config")
+	config.SetDefault(path("missing.json"))
+	if err := f.Parse([]string{"-o", "x"}); err != nil {
+		t.Fatal(err)
+	}
+	config.SetDefault(path("config.json"))
+	if err := f.Parse([]string{"-v"}); err != nil || f.GetValue("output") != "cfg.out" {
+		t.Fatalf("default config path failed: %v", err)
+	}
+}
+
+func TestConfigXDG(t *testing.T) {
+	dir := writeargsfiles(t, nil)
+	defer os.RemoveAll(dir)
+	if err := os.MkdirAll(filepath.Join(dir, "home", "app"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		filepath.Join(dir, "home", "app", "config.ini"): "output = home.out\n",
+		filepath.Join(dir, "config.ini"):                "output = dirs.out\nlevel = 3\n",
+	}
+	for name, text := range files {
+		f, err := os.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.WriteString(text)
+		f.Close()
+	}
+	defer setenv(t, map[string]string{
+		"XDG_CONFIG_HOME": filepath.Join(dir, "home"),
+		"XDG_CONFIG_DIRS": filepath.Join(dir, "none") + string(filepath.ListSeparator) + filepath.Join(dir, ".."),
+	})()
+
+	ini := DecoderFunc(func(r io.Reader) (map[string]interface{}, error) {
+		config := make(map[string]interface{})
+		scanner := bufio.NewScanner(r)
+		for scanner.Scan() {
+			if kv := strings.SplitN(scanner.Text(), "=", 2); len(kv) == 2 {
+				config[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+			}
+		}
+		return config, scanner.Err()
+	})
+	f := New()
+	f.DefineOptional("config", "c", "config file", "filename", "")
+	f.DefineSwitch("verbose", "v", "verbose")
+	f.DefineCounter("level", "l", "level")
+	f.DefineRequired("output", "o", "output", "filename", "")
+	f.SetConfig(Config{Key: "config", Name: "app/config.ini", Decoder: ini})
+	if err := f.Parse([]string{"-v"}); err != nil {
+		t.Fatal(err)
+	}
+	if f.GetValue("output") != "home.out" || f.Parsed("level") {
+		t.Fatalf("xdg config home failed: %v", f.ParseMap())
+	}
+	f.SetConfig(Config{Key: "config", Name: filepath.Base(dir) + "/config.ini", Decoder: ini})
+	if err := f.Parse([]string{"-v"}); err != nil {
+		t.Fatal(err)
+	}
+	if f.GetValue("output") != "dirs.out" || f.GetValue("level") != "3" {
+		t.Fatalf("xdg config dirs failed: %v", f.ParseMap())
+	}
+	if err := f.Parse([]string{"-c", filepath.Join(dir, "home", "app", "config.ini")}); err != nil {
+		t.Fatal(err)
+	}
+	if f.GetValue("output") != "home.out" {
+		t.Fatal("config flag does not override xdg")
+	}
+}
@@ -0,0 +1,349 @@
+// Copyright 2020 Vedran Vuk. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package flagex
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ConstraintKind specifies Constraint kind.
+type ConstraintKind byte
+
+const (
+	// ConstraintExclusive allows at most one of Keys to be parsed.
+	ConstraintExclusive ConstraintKind = iota
+	// ConstraintAtLeastOne requires at least one of Keys to be parsed.
+	ConstraintAtLeastOne
+	// ConstraintExactlyOne requires exactly one of Keys to be parsed.
+	ConstraintExactlyOne
+	// ConstraintRequires requires all of Keys to be parsed if Key is parsed
+	// and, if Value is not empty, its value is Value.
+	ConstraintRequires
+	// ConstraintConflicts allows none of Keys to be parsed if Key is parsed.
+	ConstraintConflicts
+	// ConstraintImplies sets the value of the only key in Keys to Value if
+	// Key is parsed and the key in Keys is not.
+	ConstraintImplies
+)
+
+// String implements Stringer interface on ConstraintKind.
+func (ck ConstraintKind) String() string {
+	switch ck {
+	case ConstraintExclusive:
+		return "exclusive"
+	case ConstraintAtLeastOne:
+		return "at least one"
+	case ConstraintExactlyOne:
+		return "exactly one"
+	case ConstraintRequires:
+		return "requires"
+	case ConstraintConflicts:
+		return "conflicts"
+	case ConstraintImplies:
+		return "implies"
+	}
+	return ""
+}
+
+// Constraint is a constraint on flags of Flags checked after Flags are
+// parsed, including values from environment and config. Flags in Keys are
+// referred to as parsed if they were given by any of those.
+type Constraint struct {
+	// Kind is the constraint kind.
+	Kind ConstraintKind
+	// Name is an optional name of an Exclusive, AtLeastOne or ExactlyOne
+	// group shown in help.
+	Name string
+	// Key is the key of the flag which triggers the constraint of a
+	// Requires, Conflicts or Implies constraint.
+	Key string
+	// Value is the value Key must have to trigger a Requires constraint, if
+	// not empty, or the value implied by an Implies constraint.
+	Value string
+	// Keys are keys of constrained flags.
+	Keys []string
+}
+
+// Exclusive returns a Constraint that allows at most one of flags under keys
+// to be parsed, in a group of specified name. Unlike with SetExclusive,
+// multiple exclusive groups can be added.
+func Exclusive(name string, keys ...string) Constraint {
+	return Constraint{Kind: ConstraintExclusive, Name: name, Keys: keys}
+}
+
+// AtLeastOne returns a Constraint that requires at least one of flags under
+// keys to be parsed, in a group of specified name.
+func AtLeastOne(name string, keys ...string) Constraint {
+	return Constraint{Kind: ConstraintAtLeastOne, Name: name, Keys: keys}
+}
+
+// ExactlyOne returns a Constraint that requires exactly one of flags under
+// keys to be parsed, in a group of specified name.
+func ExactlyOne(name string, keys ...string) Constraint {
+	return Constraint{Kind: ConstraintExactlyOne, Name: name, Keys: keys}
+}
+
+// Requires returns a Constraint that requires flags under keys to be parsed
+// if flag under key is parsed.
+func Requires(key string, keys ...string) Constraint {
+	return Constraint{Kind: ConstraintRequires, Key: key, Keys: keys}
+}
+
+// RequiresIf returns a Constraint that requires flags under keys to be
+// parsed if value of flag under key is value, whether it was parsed or is
+// the default or implied value. Values of switches are compared as booleans.
+func RequiresIf(key, value string, keys ...string) Constraint {
+	return Constraint{Kind: ConstraintRequires, Key: key, Value: value, Keys: keys}
+}
+
+// Conflicts returns a Constraint that allows none of flags under keys to be
+// parsed if flag under key is parsed.
+func Conflicts(key string, keys ...string) Constraint {
+	return Constraint{Kind: ConstraintConflicts, Key: key, Keys: keys}
+}
+
+// Implies returns a Constraint that sets the value of flag under target to
+// value if flag under key is parsed and flag under target is not, e.g.
+// "--tls" implying "--port 443". The implied value is not parsed, it
+// replaces the default value.
+func Implies(key, target, value string) Constraint {
+	return Constraint{Kind: ConstraintImplies, Key: key, Value: value, Keys: []string{target}}
+}
+
+// AddConstraint adds a constraint on flags of these Flags. Constraints are
+// checked after these Flags and their parsed sub, if any, are parsed and are
+// listed in help. Flags that would violate an Exclusive, ExactlyOne or
+// Conflicts constraint are not given values from environment or config.
+// Keys must already be defined and not be subs or operations.
+func (f *Flags) AddConstraint(c Constraint) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	keys := c.Keys
+	switch c.Kind {
+	case ConstraintExclusive, ConstraintAtLeastOne, ConstraintExactlyOne:
+		if len(c.Keys) < 2 {
+			return ErrConstraint.WrapArgs(fmt.Sprintf("%s needs at least two keys", c.Kind))
+		}
+	case ConstraintRequires, ConstraintConflicts, ConstraintImplies:
+		if len(c.Keys) == 0 || c.Kind == ConstraintImplies && len(c.Keys) != 1 {
+			return ErrConstraint.WrapArgs(fmt.Sprintf("invalid number of keys for %s", c.Kind))
+		}
+		keys = append([]string{c.Key}, c.Keys...)
+	default:
+		return ErrConstraint.WrapArgs("invalid kind")
+	}
+	for _, key := range keys {
+		flag, ok := f.getkey(key)
+		if !ok {
+			return ErrNotFound.WrapArgs(key)
+		}
+		if flag.sub != nil {
+			return ErrConstraint.WrapArgs(fmt.Sprintf("'%s' is a sub", key))
+		}
+	}
+	if flag, _ := f.getkey(c.Key); c.Kind == ConstraintRequires && c.Value != "" && flag.kind == KindSwitch {
+		if _, err := strconv.ParseBool(c.Value); err != nil {
+			return ErrConstraint.WrapArgs(fmt.Sprintf("'%s' is not a boolean", c.Value))
+		}
+	}
+	c.Keys = append([]string(nil), c.Keys...)
+	f.constraints = append(f.constraints, c)
+	return nil
+}
+
+// Constraints returns constraints added to Flags in order of addition.
+func (f *Flags) Constraints() []Constraint {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return append([]Constraint(nil), f.constraints...)
+}
+
+// parsedkeys returns those of flags under keys that were parsed in Result.
+func (r *Result) parsedkeys(keys []string) []*Flag {
+	var flags []*Flag
+	for _, key := range keys {
+		if flag, ok := r.flags.getkey(key); ok && r.get(flag).parsed {
+			flags = append(flags, flag)
+		}
+	}
+	return flags
+}
+
+// conflicts returns if flag would violate an Exclusive, ExactlyOne or
+// Conflicts constraint of Result Flags if parsed.
+func (r *Result) conflicts(flag *Flag) bool {
+	has := func(keys []string) bool {
+		for _, key := range keys {
+			if v, ok := r.flags.getkey(key); ok && v == flag {
+				return true
+			}
+		}
+		return false
+	}
+	for _, c := range r.flags.constraints {
+		switch c.Kind {
+		case ConstraintExclusive, ConstraintExactlyOne:
+			if has(c.Keys) && len(r.parsedkeys(c.Keys)) > 0 {
+				return true
+			}
+		case ConstraintConflicts:
+			if has(c.Keys) && len(r.parsedkeys([]string{c.Key})) > 0 ||
+				has([]string{c.Key}) && len(r.parsedkeys(c.Keys)) > 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// imply sets values implied by Implies constraints of Result Flags.
+func (r *Result) imply() error {
+	for _, c := range r.flags.constraints {
+		if c.Kind != ConstraintImplies {
+			continue
+		}
+		flag, _ := r.flags.getkey(c.Key)
+		target, _ := r.flags.getkey(c.Keys[0])
+		if !r.get(flag).parsed || r.get(target).parsed {
+			continue
+		}
+		value, err := target.process(c.Value)
+		if err != nil {
+			if err = r.fail(r.flagerror(err, target)); err != nil {
+				return err
+			}
+			continue
+		}
+		s := r.state(target)
+		s.implied = true
+		s.value, s.values = value, target.split(value)
+		s.source = Source{Kind: SourceImplied, Name: flag.key, Index: -1}
+	}
+	return nil
+}
+
+// checkconstraints checks constraints of Result Flags other than Implies.
+func (r *Result) checkconstraints() error {
+	// constrainterr returns err at flag and the arg it was parsed from.
+	constrainterr := func(err error, flag *Flag) error {
+		index := -1
+		if s := r.get(flag); s.source.Kind == SourceArgs {
+			index = s.source.Index
+		}
+		return r.newerror(err, flag, index, "")
+	}
+	for _, c := range r.flags.constraints {
+		var err error
+		parsed := r.parsedkeys(c.Keys)
+		switch c.Kind {
+		case ConstraintExclusive:
+			if len(parsed) > 1 {
+				err = constrainterr(ErrExclusive.WrapArgs(parsed[0].key, parsed[1].key), parsed[1])
+			}
+		case ConstraintAtLeastOne:
+			if len(parsed) == 0 {
+				err = r.flagerror(ErrAtLeastOne.WrapArgs(quotekeys(c.Keys)), nil)
+			}
+		case ConstraintExactlyOne:
+			if len(parsed) == 0 {
+				err = r.flagerror(ErrExactlyOne.WrapArgs(quotekeys(c.Keys)), nil)
+			} else if len(parsed) > 1 {
+				err = constrainterr(ErrExclusive.WrapArgs(parsed[0].key, parsed[1].key), parsed[1])
+			}
+		case ConstraintRequires:
+			flag, _ := r.flags.getkey(c.Key)
+			s := r.get(flag)
+			if c.Value == "" && !s.parsed || c.Value != "" && !flag.hasvalue(s, c.Value) {
+				continue
+			}
+			for _, key := range c.Keys {
+				if len(r.parsedkeys([]string{key})) == 0 {
+					err = constrainterr(ErrRequires.WrapArgs(c.trigger(), key), flag)
+					break
+				}
+			}
+		case ConstraintConflicts:
+			flag, _ := r.flags.getkey(c.Key)
+			if r.get(flag).parsed && len(parsed) > 0 {
+				err = constrainterr(ErrConflicts.WrapArgs(flag.key, parsed[0].key), parsed[0])
+			}
+		}
+		if err = r.fail(err); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// hasvalue returns if Flag value given parse state s is value. Values of
+// switches are compared as booleans.
+func (f *Flag) hasvalue(s flagstate, value string) bool {
+	if f.kind == KindSwitch {
+		b, err := strconv.ParseBool(value)
+		return err == nil && f.boolof(s) == b
+	}
+	return f.valueof(s) == value
+}
+
+// trigger returns Constraint Key with Value if a Requires constraint is
+// triggered by a value.
+func (c Constraint) trigger() string {
+	if c.Value == "" {
+		return c.Key
+	}
+	return c.Key + "=" + c.Value
+}
+
+// quotekeys returns keys quoted and separated by commas.
+func quotekeys(keys []string) string {
+	quoted := make([]string, 0, len(keys))
+	for _, key := range keys {
+		quoted = append(quoted, "'"+key+"'")
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// describe returns a printable description of Constraint using syntax.
+func (c Constraint) describe(syntax Syntax) string {
+	keys := make([]string, 0, len(c.Keys))
+	for _, key := range c.Keys {
+		keys = append(keys, syntax.Long+key)
+	}
+	list := strings.Join(keys, ", ")
+	var s string
+	switch c.Kind {
+	case ConstraintExclusive:
+		s = "at most one of " + list
+	case ConstraintAtLeastOne:
+		s = "at least one of " + list
+	case ConstraintExactlyOne:
+		s = "exactly one of " + list
+	case ConstraintRequires:
+		key := syntax.Long + c.Key
+		if c.Value != "" {
+			key += syntax.separator() + c.Value
+		}
+		s = key + " requires " + list
+	case ConstraintConflicts:
+		s = syntax.Long + c.Key + " conflicts with " + list
+	case ConstraintImplies:
+		s = syntax.Long + c.Key + " implies " + list + syntax.separator() + c.Value
+	}
+	if c.Name != "" {
+		s = c.Name + ": " + s
+	}
+	return s
+}
+
+// printconstraints prints Flags constraints to w indented with indent using
+// opts.
+func (f *Flags) printconstraints(w io.Writer, indent string, opts parseopts) {
+	for _, c := range f.constraints {
+		fmt.Fprintf(w, "%s\t\t%s\t\n", indent, c.describe(opts.syntax))
+	}
+}
@@ -0,0 +1,136 @@
+// Copyright 2020 Vedran Vuk. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package flagex
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestConstraints(t *testing.T) {
+	sub := New()
+	sub.DefineOptional("addr", "a", "listen address", "address", "")
+	sub.DefineSwitch("tls", "t", "tls")
+	sub.DefineOptional("port", "p", "port", "number", "80")
+	sub.DefineOptional("cert", "c", "certificate", "file", "")
+	sub.DefineOptional("key", "k", "key", "file", "")
+	f := New()
+	f.DefineSwitch("json", "j", "json output")
+	f.DefineSwitch("yaml", "y", "yaml output")
+	f.DefineSwitch("quiet", "q", "quiet")
+	f.DefineSwitch("verbose", "v", "verbose")
+	f.DefineOptional("token", "T", "auth token", "token", "")
+	f.DefineOptional("user", "u", "auth user", "name", "")
+	f.DefineOptional("mode", "m", "mode", "mode", "plain")
+	f.DefineSub("serve", "s", "serve", sub)
+	for _, c := range []Constraint{
+		Exclusive("format", "json", "yaml"),
+		Exclusive("", "quiet", "verbose"),
+		ExactlyOne("auth", "token", "user"),
+	} {
+		if err := f.AddConstraint(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, c := range []Constraint{
+		AtLeastOne("", "tls", "addr", "port"),
+		Requires("key", "cert"),
+		RequiresIf("port", "443", "cert"),
+		RequiresIf("tls", "true", "cert"),
+		Conflicts("addr", "tls"),
+		Implies("tls", "port", "443"),
+	} {
+		if err := sub.AddConstraint(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := f.Parse([]string{"-jq", "-T", "x", "-s", "-t", "-c", "a.pem"}); err != nil {
+		t.Fatal(err)
+	}
+	port, _ := sub.GetKey("port")
+	if port.Value() != "443" || port.Parsed() || port.Source().Kind != SourceImplied {
+		t.Fatalf("implies failed: %s %v", port.Value(), port.Source())
+	}
+	if err := f.Parse([]string{"-y", "-u", "x", "-s", "-p", "8080"}); err != nil {
+		t.Fatal(err)
+	}
+
+	type Test struct {
+		Args     string
+		Expected error
+		Key      string
+	}
+	tests := []Test{
+		{"-j -y -T x", ErrExclusive, "yaml"},
+		{"-v -q -T x", ErrExclusive, "verbose"},
+		{"-j", ErrExactlyOne, ""},
+		{"-j", ErrRequired, ""},
+		{"-T x -u y", ErrExclusive, "user"},
+		{"-T x -s -a :80 -t -c a.pem", ErrConflicts, "tls"},
+		{"-T x -s -c a.pem", ErrAtLeastOne, ""},
+		{"-T x -s -p 1 -k a.key", ErrRequires, "key"},
+		{"-T x -s -p 443", ErrRequires, "port"},
+		{"-T x -s -t", ErrRequired, "port"},
+		{"-T x -s -t -p 80", ErrRequires, "tls"},
+		{"-T x -s -t=1 -p 80", ErrRequires, "tls"},
+	}
+	for _, test := range tests {
+		err := f.Parse(strings.Split(test.Args, " "))
+		var pe *ParseError
+		if !errors.Is(err, test.Expected) || !errors.As(err, &pe) {
+			t.Fatalf("'%s': expected '%v', got '%v'", test.Args, test.Expected, err)
+		}
+		key := ""
+		if pe.Flag != nil {
+			key = pe.Flag.Key()
+		}
+		if key != test.Key {
+			t.Fatalf("'%s': unexpected flag in %+v", test.Args, pe)
+		}
+	}
+
+	defer setenv(t, map[string]string{"FLAGEXCONS_YAML": "true"})()
+	f.SetEnvPrefix("FLAGEXCONS")
+	if err := f.Parse([]string{"-j", "-T", "x"}); err != nil || f.Parsed("yaml") {
+		t.Fatalf("conflicting env applied: %v", err)
+	}
+
+	s := f.String()
+	for _, v := range []string{
+		"format: at most one of --json, --yaml",
+		"   at most one of --quiet, --verbose",
+		"auth: exactly one of --token, --user",
+		"at least one of --tls, --addr, --port",
+		"--key requires --cert",
+		"--port=443 requires --cert",
+		"--addr conflicts with --tls",
+		"--tls implies --port=443",
+	} {
+		if !strings.Contains(s, v) {
+			t.Fatalf("'%s' not in '%s'", v, s)
+		}
+	}
+
+	for _, c := range []Constraint{
+		Exclusive("", "json"),
+		Requires("json"),
+		{Kind: ConstraintImplies, Key: "json", Keys: []string{"yaml", "quiet"}},
+		{Kind: 42, Keys: []string{"json", "yaml"}},
+		Conflicts("json", "serve"),
+		RequiresIf("json", "yes", "yaml"),
+	} {
+		if err := f.AddConstraint(c); !errors.Is(err, ErrConstraint) {
+			t.Fatalf("%+v: expected ErrConstraint, got %v", c, err)
+		}
+	}
+	if err := f.AddConstraint(Requires("json", "nope")); !errors.Is(err, ErrNotFound) {
+		t.Fatal(err)
+	}
+	if len(f.Constraints()) != 3 {
+		t.Fatalf("unexpected constraints: %v", f.Constraints())
+	}
+}
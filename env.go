@@ -0,0 +1,142 @@
+// Copyright 2020 Vedran Vuk. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package flagex
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// SetEnv binds flag to environment variable under specified name, which
+// overrides the name derived from env prefix, if any. If flag is not parsed
+// from args its value is read from the environment variable, if set and not
+// empty. A switch takes a boolean and a counter a non-negative count.
+func (f *Flag) SetEnv(name string) {
+	f.flags.mu.Lock()
+	defer f.flags.mu.Unlock()
+	f.env = name
+}
+
+// Env returns name of the environment variable flag was bound to with
+// SetEnv.
+func (f *Flag) Env() string {
+	f.flags.mu.RLock()
+	defer f.flags.mu.RUnlock()
+	return f.env
+}
+
+// SetEnvPrefix sets prefix of environment variables bound to flags in these
+// Flags that were not bound with SetEnv. A flag is bound to a variable named
+// prefix, an underscore and flag key in upper case with characters other
+// than letters and digits replaced with underscores, e.g. "APP_CONFIG" for
+// flag "config" and prefix "APP". Subs and operations with no prefix set use
+// the prefix of these Flags followed by an underscore and the sub key, e.g.
+// "APP_SRVPARAMS_ADDR" for flag "addr" in sub "srvparams".
+// An empty prefix disables automatic binding.
+func (f *Flags) SetEnvPrefix(prefix string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.envprefix = prefix
+}
+
+// EnvPrefix returns prefix of environment variables bound to flags.
+func (f *Flags) EnvPrefix() string {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.envprefix
+}
+
+// envkey returns key converted to an environment variable name.
+func envkey(key string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToUpper(r)
+		}
+		return '_'
+	}, key)
+}
+
+// envname returns name of the environment variable bound to flag given env
+// prefix or an empty string if flag is not bound.
+func envname(flag *Flag, prefix string) string {
+	switch {
+	case flag.sub != nil:
+		return ""
+	case flag.env != "":
+		return flag.env
+	case prefix != "":
+		return prefix + "_" + envkey(flag.key)
+	}
+	return ""
+}
+
+// parseenv consumes values of environment variables bound to flags of the
+// Result that were not parsed from args. Flags exclusive to a parsed flag
+// or conflicting with it are skipped.
+func (r *Result) parseenv(opts parseopts) error {
+	for _, flag := range r.flags.sorted() {
+		if r.get(flag).parsed || r.excluded(flag) {
+			continue
+		}
+		name := envname(flag, opts.envprefix)
+		if name == "" {
+			continue
+		}
+		value, ok := os.LookupEnv(name)
+		if !ok || value == "" {
+			continue
+		}
+		valid, err := r.consumefallback(flag, value, Source{Kind: SourceEnv, Name: name, Index: -1})
+		if err == nil && !valid {
+			err = r.flagerror(ErrEnv.WrapArgs(value, name), flag)
+		}
+		if err = r.fail(err); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// consumefallback consumes value of flag from a source other than args.
+// A switch takes a boolean and a counter a non-negative count. It returns
+// false if value is invalid for flag.
+func (r *Result) consumefallback(flag *Flag, value string, source Source) (bool, error) {
+	switch flag.kind {
+	case KindSwitch:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return false, nil
+		}
+		value = strconv.FormatBool(b)
+	case KindCounter:
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return false, nil
+		}
+		if n > 0 {
+			s := r.state(flag)
+			s.parsed = true
+			s.count += n
+			s.source = source
+		}
+		return true, nil
+	}
+	return true, r.consumefrom(flag, value, source)
+}
+
+// excluded returns if flag is exclusive to another flag parsed in Result or
+// would violate a constraint if parsed.
+func (r *Result) excluded(flag *Flag) bool {
+	if flag.excl {
+		for _, v := range r.flags.keys {
+			if v != flag && v.excl && r.get(v).parsed {
+				return true
+			}
+		}
+	}
+	return r.conflicts(flag)
+}
@@ -0,0 +1,91 @@
+// Copyright 2020 Vedran Vuk. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package flagex
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+func setenv(t *testing.T, env map[string]string) func() {
+	for k, v := range env {
+		if err := os.Setenv(k, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return func() {
+		for k := range env {
+			os.Unsetenv(k)
+		}
+	}
+}
+
+func TestEnv(t *testing.T) {
+	sub := New()
+	sub.DefineOptional("addr", "a", "listen address", "address", ":80")
+	f := New()
+	f.DefineRequired("config", "c", "config file", "filename", "")
+	f.DefineSwitch("verbose", "v", "verbose")
+	f.DefineCounter("level", "l", "level")
+	f.DefineSwitch("color", "", "color output")
+	f.DefineSub("srvparams", "s", "server params", sub)
+	color, _ := f.GetKey("color")
+	color.SetEnv("FLAGEXTEST_COLOUR")
+	if err := f.Parse([]string{"-c", "a.json"}); err != nil {
+		t.Fatal(err)
+	}
+	f.SetEnvPrefix("FLAGEXTEST")
+
+	defer setenv(t, map[string]string{
+		"FLAGEXTEST_CONFIG":         "env.json",
+		"FLAGEXTEST_VERBOSE":        "true",
+		"FLAGEXTEST_LEVEL":          "3",
+		"FLAGEXTEST_COLOUR":         "0",
+		"FLAGEXTEST_SRVPARAMS_ADDR": ":8080",
+	})()
+
+	if err := f.Parse([]string{}); err != nil {
+		t.Fatal(err)
+	}
+	if f.GetValue("config") != "env.json" || f.GetValue("level") != "3" {
+		t.Fatalf("env failed: %v", f.ParseMap())
+	}
+	if v, _ := f.GetKey("verbose"); !v.Bool() || !color.Parsed() || color.Bool() {
+		t.Fatal("env switch failed")
+	}
+	if err := f.Parse([]string{"--config", "argv.json", "-s", "www"}); err != nil {
+		t.Fatal(err)
+	}
+	if f.GetValue("config") != "argv.json" || sub.GetValue("addr") != ":8080" {
+		t.Fatalf("env precedence failed: %v", f.ParseMap())
+	}
+
+	s := f.String()
+	for _, v := range []string{"[env: FLAGEXTEST_CONFIG]", "[env: FLAGEXTEST_COLOUR]", "[env: FLAGEXTEST_SRVPARAMS_ADDR]"} {
+		if !strings.Contains(s, v) {
+			t.Fatalf("'%s' not in '%s'", v, s)
+		}
+	}
+	if strings.Contains(s, "FLAGEXTEST_COLOR") || strings.Contains(s, "FLAGEXTEST_SRVPARAMS]") {
+		t.Fatalf("unbound env in '%s'", s)
+	}
+
+	os.Setenv("FLAGEXTEST_VERBOSE", "maybe")
+	if err := f.Parse([]string{"-c", "a.json"}); !errors.Is(err, ErrEnv) {
+		t.Fatal(err)
+	}
+	os.Unsetenv("FLAGEXTEST_VERBOSE")
+	os.Unsetenv("FLAGEXTEST_CONFIG")
+	if err := f.Parse([]string{"-v"}); !errors.Is(err, ErrRequired) {
+		t.Fatal(err)
+	}
+	f.SetEnvPrefix("")
+	os.Setenv("FLAGEXTEST_CONFIG", "env.json")
+	if err := f.Parse([]string{"-v"}); !errors.Is(err, ErrRequired) {
+		t.Fatal(err)
+	}
+}
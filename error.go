@@ -0,0 +1,144 @@
+// Copyright 2020 Vedran Vuk. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package flagex
+
+import (
+	"errors"
+	"strings"
+)
+
+// ParseError is an error that occurred while parsing args. Errors returned
+// by Parse and Evaluate are of type *ParseError or, if errors are collected
+// with SetCollectErrors, ParseErrors.
+type ParseError struct {
+	// Err is the error that occurred, derived from one of the package
+	// errors which can be tested with errors.Is, e.g. ErrReqVal.
+	Err error
+	// Flag is the flag the error occurred at or nil if none.
+	Flag *Flag
+	// Path holds keys of subs and operations, in order of nesting, leading
+	// to the Flags in which the error occurred.
+	Path []string
+	// Index is the index of the arg in args given to Parse the error
+	// occurred at or -1 if the error did not occur at an arg. Errors in args
+	// read from an args file have the index of the arg naming the file.
+	Index int
+	// Token is the arg the error occurred at or an empty string if none.
+	Token string
+}
+
+// Error implements error interface on ParseError.
+func (pe *ParseError) Error() string { return pe.Err.Error() }
+
+// Unwrap returns the error that occurred.
+func (pe *ParseError) Unwrap() error { return pe.Err }
+
+// ParseErrors holds all errors that occurred while parsing args if errors
+// are collected, see SetCollectErrors.
+type ParseErrors []*ParseError
+
+// Error implements error interface on ParseErrors.
+func (pe ParseErrors) Error() string {
+	a := make([]string, 0, len(pe))
+	for _, err := range pe {
+		a = append(a, err.Error())
+	}
+	return strings.Join(a, "\n")
+}
+
+// Is returns true if any of the errors matches target.
+func (pe ParseErrors) Is(target error) bool {
+	for _, err := range pe {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// Unwrap returns the first error so errors.As finds the first ParseError.
+func (pe ParseErrors) Unwrap() error {
+	if len(pe) == 0 {
+		return nil
+	}
+	return pe[0]
+}
+
+// SetCollectErrors sets if Parse continues parsing after an error and
+// returns all errors that occurred as ParseErrors instead of stopping at the
+// first one. Args files, config files and sub args are still not processed
+// if they could not be read or are missing. Only the setting of the Flags
+// being parsed is used and not of their subs.
+func (f *Flags) SetCollectErrors(collect bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.collect = collect
+}
+
+// CollectErrors returns if Parse collects all errors that occurred.
+func (f *Flags) CollectErrors() bool {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.collect
+}
+
+// subpath returns keys of subs and operations leading to Result from root.
+func (r *Result) subpath() []string {
+	var path []string
+	for p := r.parent; p != nil; p = p.parent {
+		path = append([]string{p.subflag.key}, path...)
+	}
+	return path
+}
+
+// newerror returns err as a ParseError that occurred in Result at flag, if
+// not nil, and at token at index in args. If err is nil, a ParseError or
+// ParseErrors it is returned unmodified.
+func (r *Result) newerror(err error, flag *Flag, index int, token string) error {
+	switch err.(type) {
+	case nil, *ParseError, ParseErrors:
+		return err
+	}
+	return &ParseError{
+		Err:   err,
+		Flag:  flag,
+		Path:  r.subpath(),
+		Index: index,
+		Token: token,
+	}
+}
+
+// argerror returns err as a ParseError that occurred in Result at flag, if
+// not nil, and at the arg being parsed.
+func (r *Result) argerror(err error, flag *Flag) error {
+	return r.newerror(err, flag, r.cur, r.token)
+}
+
+// flagerror returns err as a ParseError that occurred in Result at flag, if
+// not nil, but not at an arg.
+func (r *Result) flagerror(err error, flag *Flag) error {
+	return r.newerror(err, flag, -1, "")
+}
+
+// fail collects err if errors are collected and returns nil or returns err
+// otherwise. If err is nil, nil is returned.
+func (r *Result) fail(err error) error {
+	if err == nil {
+		return nil
+	}
+	root := r.root()
+	if !root.collect {
+		return err
+	}
+	switch e := err.(type) {
+	case *ParseError:
+		root.errs = append(root.errs, e)
+	case ParseErrors:
+		root.errs = append(root.errs, e...)
+	default:
+		root.errs = append(root.errs, r.flagerror(err, nil).(*ParseError))
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+// Copyright 2020 Vedran Vuk. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package flagex
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseError(t *testing.T) {
+	sub := New()
+	sub.DefineRequired("addr", "a", "listen address", "address", "")
+	sub.DefineSwitch("tls", "t", "tls")
+	f := New()
+	f.DefineRequired("config", "c", "config file", "filename", "")
+	f.DefineRequired("output", "o", "output", "filename", "")
+	f.DefineSwitch("verbose", "v", "verbose")
+	f.DefineSub("srvparams", "s", "server params", sub)
+	f.DefinePositional("input", "input file", ArityOptional)
+
+	type Test struct {
+		Args     string
+		Expected error
+		Key      string
+		Path     string
+		Index    int
+		Token    string
+	}
+	tests := []Test{
+		{"-c a -o b -v -v", ErrDuplicate, "verbose", "", 5, "-v"},
+		{"-c a -o b --verbose=x", ErrSwitch, "verbose", "", 4, "--verbose=x"},
+		{"-c a -o b -s -t --addr", ErrReqVal, "addr", "srvparams", 6, "--addr"},
+		{"-c a -o b -s -t", ErrRequired, "addr", "srvparams", -1, ""},
+		{"-c a -o b --nope", ErrNotFound, "", "", 4, "--nope"},
+		{"-c a", ErrRequired, "output", "", -1, ""},
+		{"-c a -o b in -v extra", ErrOperand, "", "", 6, "extra"},
+	}
+	for _, test := range tests {
+		err := f.Parse(strings.Split(test.Args, " "))
+		var pe *ParseError
+		if !errors.As(err, &pe) || !errors.Is(err, test.Expected) {
+			t.Fatalf("'%s': expected '%v', got '%v'", test.Args, test.Expected, err)
+		}
+		key := ""
+		if pe.Flag != nil {
+			key = pe.Flag.Key()
+		}
+		if key != test.Key || strings.Join(pe.Path, ".") != test.Path || pe.Index != test.Index || pe.Token != test.Token {
+			t.Fatalf("'%s': unexpected error %+v", test.Args, pe)
+		}
+	}
+}
+
+func TestCollectErrors(t *testing.T) {
+	sub := New()
+	sub.DefineRequired("addr", "a", "listen address", "address", "")
+	sub.DefineSwitch("tls", "t", "tls")
+	f := New()
+	f.DefineRequired("config", "c", "config file", "filename", "")
+	f.DefineRequired("output", "o", "output", "filename", "")
+	f.DefineSwitch("verbose", "v", "verbose")
+	f.DefineSub("srvparams", "s", "server params", sub)
+	f.DefinePositional("input", "input file", ArityOptional)
+	f.SetCollectErrors(true)
+	err := f.Parse([]string{"--nope", "-v", "-v", "-s", "-t=x", "-u"})
+	var errs ParseErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("expected ParseErrors, got '%v'", err)
+	}
+	expected := []error{ErrNotFound, ErrDuplicate, ErrSwitch, ErrNotFound, ErrRequired, ErrRequired, ErrRequired}
+	if len(errs) != len(expected) {
+		t.Fatalf("expected %d errors, got '%v'", len(expected), err)
+	}
+	for i, e := range expected {
+		if !errors.Is(errs[i], e) {
+			t.Fatalf("error %d: expected '%v', got '%v'", i, e, errs[i])
+		}
+	}
+	if key := errs[4].Flag.Key(); key != "addr" || errs[4].Path[0] != "srvparams" {
+		t.Fatalf("sub error failed: %+v", errs[4])
+	}
+	if !errors.Is(err, ErrSwitch) || errors.Is(err, ErrNoArgs) {
+		t.Fatal("ParseErrors Is failed")
+	}
+	var pe *ParseError
+	if !errors.As(err, &pe) || pe.Token != "--nope" {
+		t.Fatal("ParseErrors As failed")
+	}
+	if f.Parsed() {
+		t.Fatal("parsed with errors")
+	}
+	if err := f.Parse([]string{"-c", "a", "-o", "b"}); err != nil || !f.Parsed() {
+		t.Fatal(err)
+	}
+}
@@ -2,15 +2,22 @@
 // Use of this source code is governed by a MIT
 // license that can be found in the LICENSE file.
 
-// Package flagex implements a command line parser. Not thread-safe.
+// Package flagex implements a command line parser.
+//
+// Flags are safe for concurrent use. As Parse stores its result in Flags,
+// concurrent Parse calls overwrite each other's results; Evaluate returns
+// the result instead and should be used to parse concurrently.
 package flagex
 
 import (
 	"bytes"
 	"fmt"
 	"io"
+	"os"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"text/tabwriter"
 
 	"github.com/vedranvuk/errorex"
@@ -47,6 +54,79 @@ var (
 	// ErrNotSub is returned when a non-sub switch is combined with other
 	// commands.
 	ErrNotSub = ErrFlagex.WrapFormat("cannot combine key '%s', not a sub.")
+	// ErrPosRequired is returned when a required positional was not parsed.
+	ErrPosRequired = ErrRequired.WrapFormat("required positional '%s' not specified")
+	// ErrPosOrder is returned when a positional is defined in an order that
+	// would make binding operands to positionals ambiguous.
+	ErrPosOrder = ErrFlagex.WrapFormat("positional '%s' cannot follow '%s'")
+	// ErrOperand is returned when an operand was parsed that could not be
+	// bound to any defined positional.
+	ErrOperand = ErrFlagex.WrapFormat("unexpected operand '%s'")
+	// ErrNegate is returned when a flag that is not a switch is negated.
+	ErrNegate = ErrFlagex.WrapFormat("key '%s' cannot be negated")
+	// ErrOperation is returned when more than one operation is parsed.
+	ErrOperation = ErrFlagex.WrapFormat("operation '%s' cannot be combined with operation '%s'")
+	// ErrNoOperation is returned when no operation was parsed in Flags that
+	// define operations.
+	ErrNoOperation = ErrFlagex.Wrap("no operation specified")
+	// ErrArgsFile is returned when an args file could not be expanded. It
+	// points at the file and line where the error occurred or, for args
+	// files named on the command line, at "argv" and the index of the arg.
+	ErrArgsFile = ErrFlagex.WrapFormat("%s:%d: %s")
+	// ErrEnv is returned when an environment variable bound to a flag has a
+	// value that is invalid for the flag.
+	ErrEnv = ErrFlagex.WrapFormat("invalid value '%s' of environment variable '%s'")
+	// ErrConfig is returned when a config file could not be read or decoded.
+	ErrConfig = ErrFlagex.WrapFormat("config file '%s': %s")
+	// ErrConfigKey is returned when a config file has a value under a key
+	// that addresses no defined flag or is invalid for the flag.
+	ErrConfigKey = ErrFlagex.WrapFormat("invalid config key '%s'")
+	// ErrAmbiguous is returned when an abbreviated key matches more than one
+	// defined key.
+	ErrAmbiguous = ErrFlagex.WrapFormat("key '%s' is ambiguous, candidates: %s")
+	// ErrValue is returned when a value is invalid for a flag. If the value
+	// was rejected by a validator, the validator error is its cause.
+	ErrValue = ErrFlagex.WrapFormat("invalid value '%s' for key '%s'")
+	// ErrSplit is returned when a command string could not be split into
+	// args. It points at the line and column where the error occurred.
+	ErrSplit = ErrFlagex.WrapFormat("%d:%d: %s")
+	// ErrDidYouMean is returned instead of ErrNotFound when an unknown flag
+	// is similar to defined flags. It derives from ErrNotFound and carries
+	// the suggested flags as data, see Suggestions.
+	ErrDidYouMean = ErrNotFound.WrapFormat("key '%s' not found, did you mean %s?")
+	// ErrChoice is returned when a value that is not one of flag choices is
+	// parsed.
+	ErrChoice = ErrFlagex.WrapFormat("invalid value '%s' for key '%s', choose one of: %s")
+	// ErrEmpty is returned by NonEmpty for an empty value.
+	ErrEmpty = ErrFlagex.Wrap("empty value")
+	// ErrRange is returned by IntRange and FloatRange for a value that is not
+	// a number in range.
+	ErrRange = ErrFlagex.WrapFormat("value '%s' not in range [%v, %v]")
+	// ErrMatch is returned by Matches for a value that does not match.
+	ErrMatch = ErrFlagex.WrapFormat("value '%s' does not match '%s'")
+	// ErrURL is returned by ValidURL for a value that is not an absolute
+	// URL.
+	ErrURL = ErrFlagex.WrapFormat("invalid URL '%s'")
+	// ErrHostPort is returned by ValidHostPort for a value that is not a
+	// host and port pair.
+	ErrHostPort = ErrFlagex.WrapFormat("invalid host:port '%s'")
+	// ErrIP is returned by ValidIP for a value that is not an IP address.
+	ErrIP = ErrFlagex.WrapFormat("invalid IP address '%s'")
+	// ErrConstraint is returned by AddConstraint for an invalid constraint.
+	ErrConstraint = ErrFlagex.WrapFormat("invalid constraint: %s")
+	// ErrAtLeastOne is returned when none of the flags of which at least one
+	// is required was parsed.
+	ErrAtLeastOne = ErrRequired.WrapFormat("at least one of %s required")
+	// ErrExactlyOne is returned when none of the flags of which exactly one
+	// is required was parsed. If more than one was parsed ErrExclusive is
+	// returned.
+	ErrExactlyOne = ErrRequired.WrapFormat("exactly one of %s required")
+	// ErrRequires is returned when a flag that requires another flag was
+	// parsed without it.
+	ErrRequires = ErrRequired.WrapFormat("'%s' requires '%s'")
+	// ErrConflicts is returned when a flag was parsed along with a flag it
+	// conflicts with.
+	ErrConflicts = ErrFlagex.WrapFormat("'%s' conflicts with '%s'")
 )
 
 // FlagKind specifies Flag kind.
@@ -62,6 +142,15 @@ const (
 	KindSwitch
 	// KindSub marks a flag as a Flags subcategory prefix.
 	KindSub
+	// KindCounter marks a flag as an optional switch that takes no params and
+	// counts the number of times it was parsed.
+	KindCounter
+	// KindOptionalParam marks a flag as optional as well as its param which
+	// can only be passed inline, never as the following arg.
+	KindOptionalParam
+	// KindOperation marks a flag as an operation with its own set of options.
+	// Exactly one operation must be parsed in Flags that define operations.
+	KindOperation
 )
 
 // String implements Stringer interface on FlagKind.
@@ -75,6 +164,12 @@ func (fk FlagKind) String() string {
 		return "switch"
 	case KindSub:
 		return "sub"
+	case KindCounter:
+		return "counter"
+	case KindOptionalParam:
+		return "optional param"
+	case KindOperation:
+		return "operation"
 	}
 	return ""
 }
@@ -83,12 +178,29 @@ func (fk FlagKind) String() string {
 type Flag struct {
 	key, shortkey, help, paramhelp, defval string
 
-	kind      FlagKind
-	sub       *Flags
-	excl      bool
+	kind        FlagKind
+	flags       *Flags
+	sub         *Flags
+	excl        bool
+	repeatable  bool
+	negatable   bool
+	sep         string
+	env         string
+	choices     []Choice
+	fold        bool
+	validators  []Validator
+	normalizers []Normalizer
+}
+
+// flagstate holds parse state of a Flag.
+type flagstate struct {
 	parsed    bool
 	parsedval bool
+	count     int
 	value     string
+	values    []string
+	source    Source
+	implied   bool
 }
 
 // Key returns Flag key.
@@ -98,109 +210,543 @@ func (f *Flag) Key() string { return f.key }
 func (f *Flag) Shortkey() string { return f.shortkey }
 
 // Help returns Flag help text.
-func (f *Flag) Help() string { return f.help }
+func (f *Flag) Help() string {
+	f.flags.mu.RLock()
+	defer f.flags.mu.RUnlock()
+	return f.help
+}
 
 // ParamHelp returns Flag param help text.
-func (f *Flag) ParamHelp() string { return f.paramhelp }
+func (f *Flag) ParamHelp() string {
+	f.flags.mu.RLock()
+	defer f.flags.mu.RUnlock()
+	return f.paramhelp
+}
 
 // Default is returned by Value if no value was parsed for this Flag.
-func (f *Flag) Default() string { return f.defval }
+func (f *Flag) Default() string {
+	f.flags.mu.RLock()
+	defer f.flags.mu.RUnlock()
+	return f.defval
+}
 
 // Kind returns Flag kind.
 func (f *Flag) Kind() FlagKind { return f.kind }
 
-// Sub returns flags sub flags, if any.
+// Sub returns flags sub flags or operation options, if any.
 func (f *Flag) Sub() *Flags { return f.sub }
 
 // Excl returns if this flag is exclusive in Flags.
-func (f *Flag) Excl() bool { return f.excl }
+func (f *Flag) Excl() bool {
+	f.flags.mu.RLock()
+	defer f.flags.mu.RUnlock()
+	return f.excl
+}
 
-// Parsed returns if this Flag was parsed.
-func (f *Flag) Parsed() bool { return f.parsed }
+// Parsed returns if this Flag was parsed at last Parse.
+func (f *Flag) Parsed() bool {
+	f.flags.mu.RLock()
+	defer f.flags.mu.RUnlock()
+	return f.state().parsed
+}
 
 // ParsedVal returns if Flag as well as a parameter to it value was parsed.
-func (f *Flag) ParsedVal() bool { return f.parsedval }
+func (f *Flag) ParsedVal() bool {
+	f.flags.mu.RLock()
+	defer f.flags.mu.RUnlock()
+	return f.state().parsedval
+}
+
+// Repeatable returns if Flag may be parsed more than once.
+func (f *Flag) Repeatable() bool {
+	f.flags.mu.RLock()
+	defer f.flags.mu.RUnlock()
+	return f.repeatable
+}
+
+// Negatable returns if Flag is a switch that can be negated.
+func (f *Flag) Negatable() bool {
+	f.flags.mu.RLock()
+	defer f.flags.mu.RUnlock()
+	return f.negatable
+}
+
+// Separator returns Flag value separator.
+func (f *Flag) Separator() string {
+	f.flags.mu.RLock()
+	defer f.flags.mu.RUnlock()
+	return f.sep
+}
+
+// Count returns the number of times Flag was parsed.
+func (f *Flag) Count() int {
+	f.flags.mu.RLock()
+	defer f.flags.mu.RUnlock()
+	return f.state().count
+}
 
 // Value returns current Flag value.
+// If Flag is repeatable, last parsed value is returned.
+// If Flag is a parsed counter, parse count is returned.
 func (f *Flag) Value() string {
-	if !f.parsed || !f.parsedval {
-		return f.defval
+	f.flags.mu.RLock()
+	defer f.flags.mu.RUnlock()
+	return f.valueof(f.state())
+}
+
+// Values returns all parsed Flag values in order of appearance.
+// If Flag has a separator set, each parsed value is split by it.
+// If no values were parsed, returns default value split by separator
+// or nil if default value is empty.
+func (f *Flag) Values() []string {
+	f.flags.mu.RLock()
+	defer f.flags.mu.RUnlock()
+	return f.valuesof(f.state())
+}
+
+// Bool returns Flag value as a boolean.
+// A switch parsed without a param is true, a switch parsed with a boolean
+// param or negated has the parsed value. If Flag was not parsed or its value
+// is not a boolean the default value is converted, if possible.
+// Combined with Parsed it distinguishes an explicitly false switch from one
+// that was not parsed.
+func (f *Flag) Bool() bool {
+	f.flags.mu.RLock()
+	defer f.flags.mu.RUnlock()
+	return f.boolof(f.state())
+}
+
+// state returns Flag parse state from last Parse of Flags defining it.
+// Caller must hold the read lock of Flags defining it.
+func (f *Flag) state() flagstate {
+	return f.flags.result.get(f)
+}
+
+// valueof returns Flag value given parse state s.
+func (f *Flag) valueof(s flagstate) string {
+	if f.kind == KindCounter && s.parsed {
+		return strconv.Itoa(s.count)
+	}
+	if !s.parsed && s.implied {
+		return s.value
+	}
+	if !s.parsed || !s.parsedval {
+		return f.defvalue()
 	}
-	return f.value
+	return s.value
+}
+
+// valuesof returns Flag values given parse state s.
+func (f *Flag) valuesof(s flagstate) []string {
+	if !s.parsed && s.implied {
+		return s.values
+	}
+	if !s.parsed || !s.parsedval {
+		if f.defval == "" {
+			return nil
+		}
+		return f.split(f.defvalue())
+	}
+	return s.values
+}
+
+// boolof returns Flag value as a boolean given parse state s.
+func (f *Flag) boolof(s flagstate) bool {
+	if s.parsed && !s.parsedval {
+		return true
+	}
+	b, _ := strconv.ParseBool(f.valueof(s))
+	return b
+}
+
+// isswitch returns if Flag takes no params.
+func (f *Flag) isswitch() bool {
+	return f.kind == KindSwitch || f.kind == KindCounter
+}
+
+// split splits value by Flag separator, if set.
+func (f *Flag) split(value string) []string {
+	if f.sep == "" {
+		return []string{value}
+	}
+	return strings.Split(value, f.sep)
 }
 
 // SetHelp sets flag's help text.
 func (f *Flag) SetHelp(help string) {
+	f.flags.mu.Lock()
+	defer f.flags.mu.Unlock()
 	f.help = help
 }
 
 // SetParamHelp sets flag's param help text.
 func (f *Flag) SetParamHelp(help string) {
+	f.flags.mu.Lock()
+	defer f.flags.mu.Unlock()
 	f.paramhelp = help
 }
 
 // SetDefault sets flag's default value.
 func (f *Flag) SetDefault(defval string) {
+	f.flags.mu.Lock()
+	defer f.flags.mu.Unlock()
 	f.defval = defval
 }
 
+// SetRepeatable sets if flag may be parsed more than once in which case
+// all parsed values are recorded instead of returning an error.
+func (f *Flag) SetRepeatable(repeatable bool) {
+	f.flags.mu.Lock()
+	defer f.flags.mu.Unlock()
+	f.repeatable = repeatable
+}
+
+// SetNegatable sets if a switch flag can be negated with a "--no-" prefix
+// to its key. A negatable switch may be parsed more than once and the value
+// parsed last is used. If flag is not a switch ErrNegate is returned.
+func (f *Flag) SetNegatable(negatable bool) error {
+	f.flags.mu.Lock()
+	defer f.flags.mu.Unlock()
+	if f.kind != KindSwitch {
+		return ErrNegate.WrapArgs(f.key)
+	}
+	f.negatable = negatable
+	return nil
+}
+
+// SetSeparator sets flag's value separator. If not empty, each parsed value
+// is split by sep into multiple values.
+func (f *Flag) SetSeparator(sep string) {
+	f.flags.mu.Lock()
+	defer f.flags.mu.Unlock()
+	f.sep = sep
+}
+
+// UnknownPolicy specifies how Parse handles args that address no defined
+// flags.
+type UnknownPolicy byte
+
+const (
+	// UnknownError makes Parse return ErrNotFound on unknown flags.
+	UnknownError UnknownPolicy = iota
+	// UnknownIgnore makes Parse skip unknown flags and their params.
+	UnknownIgnore
+	// UnknownPass makes Parse collect unknown flags and their params in order
+	// of appearance. They are retrievable with Unknown.
+	UnknownPass
+)
+
+// String implements Stringer interface on UnknownPolicy.
+func (up UnknownPolicy) String() string {
+	switch up {
+	case UnknownError:
+		return "error"
+	case UnknownIgnore:
+		return "ignore"
+	case UnknownPass:
+		return "pass"
+	}
+	return ""
+}
+
+// ParseMode specifies how Parse handles flags following operands.
+type ParseMode byte
+
+const (
+	// ModeDefault uses the parse mode of the parent Flags if Flags are
+	// parsed as a sub or ModeInterspersed otherwise.
+	ModeDefault ParseMode = iota
+	// ModeInterspersed parses flags and operands in any order.
+	ModeInterspersed
+	// ModePOSIX stops parsing flags at first operand and treats it and all
+	// args following it as operands.
+	ModePOSIX
+	// ModeEnv uses ModePOSIX if POSIXLY_CORRECT environment variable is set
+	// or ModeInterspersed otherwise.
+	ModeEnv
+)
+
+// String implements Stringer interface on ParseMode.
+func (pm ParseMode) String() string {
+	switch pm {
+	case ModeDefault:
+		return "default"
+	case ModeInterspersed:
+		return "interspersed"
+	case ModePOSIX:
+		return "posix"
+	case ModeEnv:
+		return "env"
+	}
+	return ""
+}
+
+// Syntax specifies flag prefixes, inline value separators and terminator
+// recognized by Parse and used when printing Flags.
+type Syntax struct {
+	// Long is the prefix of long keys.
+	Long string
+	// Short is the prefix of shortkeys. If it equals Long, args are matched
+	// against long keys first.
+	Short string
+	// Negate is an optional prefix which turns off switches addressed by
+	// long key or, possibly combined, shortkeys. If set, switches may be
+	// turned on and off more than once and the value parsed last is used,
+	// as with negatable switches.
+	Negate string
+	// Separators is a set of characters any of which separates a key from an
+	// inline value. If empty, values cannot be passed inline.
+	Separators string
+	// Terminator is an optional arg which terminates flag parsing.
+	Terminator string
+}
+
+var (
+	// DefaultSyntax is the default syntax as in "--key=value", "-k=value",
+	// "-kvalue" with "--" as a terminator.
+	DefaultSyntax = Syntax{"--", "-", "", "=", "--"}
+	// WindowsSyntax is a Windows-like syntax as in "/key:value", "/k:value",
+	// "/key=value" or "/k=value" with no terminator.
+	WindowsSyntax = Syntax{"/", "/", "", ":=", ""}
+	// PlusMinusSyntax is a syntax where flags are prefixed with a "+" and
+	// switches are turned off with a "-" prefix as in "+key=value", "+k",
+	// "-key", "-k" with "--" as a terminator.
+	PlusMinusSyntax = Syntax{"+", "+", "-", "=", "--"}
+)
+
+// islong returns if arg starts with long key prefix and should be matched
+// against long keys before shortkeys.
+func (s Syntax) islong(arg string) bool {
+	if !strings.HasPrefix(arg, s.Long) {
+		return false
+	}
+	return len(s.Long) >= len(s.Short) || !strings.HasPrefix(arg, s.Short)
+}
+
+// isflag returns if arg starts with any of the flag prefixes. A bare
+// shortkey or negate prefix, such as "-" commonly standing for stdin, is an
+// operand and not a flag.
+func (s Syntax) isflag(arg string) bool {
+	if arg == s.Short || arg == s.Negate {
+		return false
+	}
+	return strings.HasPrefix(arg, s.Long) || strings.HasPrefix(arg, s.Short) ||
+		(s.Negate != "" && strings.HasPrefix(arg, s.Negate))
+}
+
+// split splits arg into a key and an inline value separated by first of
+// separators. If arg contains no inline value, value is empty and truth is
+// false.
+func (s Syntax) split(arg string) (key, value string, truth bool) {
+	if s.Separators == "" {
+		return arg, "", false
+	}
+	if i := strings.IndexAny(arg, s.Separators); i >= 0 {
+		return arg[:i], arg[i+1:], true
+	}
+	return arg, "", false
+}
+
+// separator returns first of syntax separators or "=" if none.
+func (s Syntax) separator() string {
+	if s.Separators == "" {
+		return "="
+	}
+	return s.Separators[:1]
+}
+
+// Normalization specifies how keys and shortkeys are normalized when defining
+// and looking up flags. Normalization values can be combined.
+type Normalization byte
+
+const (
+	// NormFoldKeys makes long keys case insensitive.
+	NormFoldKeys Normalization = 1 << iota
+	// NormDashes makes "_" and "-" equivalent in long keys.
+	NormDashes
+	// NormFoldShort makes shortkeys case insensitive.
+	NormFoldShort
+)
+
 // Flags holds a set of unique flags.
+// Flags and their flags are safe for concurrent use.
 type Flags struct {
+	mu     sync.RWMutex
 	keys   map[string]*Flag
 	short  map[string]string
-	parsed bool
+	pos    []*Positional
+	result *Result
+
+	mode          ParseMode
+	syntax        Syntax
+	norm          Normalization
+	abbrev        bool
+	argsfiles     bool
+	envprefix     string
+	config        Config
+	constraints   []Constraint
+	collect       bool
+	unknownpolicy UnknownPolicy
 }
 
 // New creates a new Flags instance.
 func New() *Flags {
-	return &Flags{
+	f := &Flags{
 		keys:  make(map[string]*Flag),
 		short: make(map[string]string),
 	}
+	f.result = newresult(f, nil)
+	return f
 }
 
-// define defines a flag.
+// define defines a flag. Caller must hold the write lock.
 func (f *Flags) define(key, shortkey, help, paramhelp, defval string, typ FlagKind) (*Flag, error) {
 	if key == "" {
 		return nil, ErrInvalid
 	}
-	if _, ok := f.keys[key]; ok {
+	if _, ok := f.getkey(key); ok {
 		return nil, ErrDuplicate.WrapArgs(key)
 	}
-	if _, ok := f.short[shortkey]; shortkey != "" && ok {
+	if _, ok := f.getshortkey(shortkey); shortkey != "" && ok {
 		return nil, ErrDupShort.WrapArgs(shortkey)
 	}
-	flag := &Flag{key, shortkey, help, paramhelp, defval, typ, nil, false, false, false, ""}
-	f.keys[key] = flag
+	for _, op := range f.keys {
+		if op.kind != KindOperation {
+			continue
+		}
+		op.sub.mu.RLock()
+		_, dupkey := op.sub.getkey(key)
+		_, dupshort := op.sub.getshortkey(shortkey)
+		op.sub.mu.RUnlock()
+		if dupkey {
+			return nil, ErrDuplicate.WrapArgs(key)
+		}
+		if shortkey != "" && dupshort {
+			return nil, ErrDupShort.WrapArgs(shortkey)
+		}
+	}
+	flag := &Flag{key: key, shortkey: shortkey, help: help, paramhelp: paramhelp, defval: defval, kind: typ, flags: f}
+	f.keys[f.normkey(key)] = flag
 	if shortkey != "" {
-		f.short[shortkey] = key
+		f.short[f.normshort(shortkey)] = key
 	}
 	return flag, nil
 }
 
+// normkey returns key normalized according to Flags normalization.
+func (f *Flags) normkey(key string) string {
+	if f.norm&NormFoldKeys != 0 {
+		key = strings.ToLower(key)
+	}
+	if f.norm&NormDashes != 0 {
+		key = strings.ReplaceAll(key, "_", "-")
+	}
+	return key
+}
+
+// normshort returns shortkey normalized according to Flags normalization.
+func (f *Flags) normshort(shortkey string) string {
+	if f.norm&NormFoldShort != 0 {
+		shortkey = strings.ToLower(shortkey)
+	}
+	return shortkey
+}
+
+// SetNormalization sets how keys and shortkeys of flags in these Flags are
+// normalized when defining and looking up flags. Already defined flags are
+// renormalized. If renormalizing would make two defined keys or shortkeys
+// equal an error is returned and normalization is not changed.
+// Default is no normalization. Subs use their own normalization.
+func (f *Flags) SetNormalization(norm Normalization) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	old := f.norm
+	f.norm = norm
+	keys := make(map[string]*Flag, len(f.keys))
+	short := make(map[string]string, len(f.short))
+	for _, flag := range f.keys {
+		key := f.normkey(flag.key)
+		if _, ok := keys[key]; ok {
+			f.norm = old
+			return ErrDuplicate.WrapArgs(flag.key)
+		}
+		keys[key] = flag
+		if flag.shortkey == "" {
+			continue
+		}
+		shortkey := f.normshort(flag.shortkey)
+		if _, ok := short[shortkey]; ok {
+			f.norm = old
+			return ErrDupShort.WrapArgs(flag.shortkey)
+		}
+		short[shortkey] = flag.key
+	}
+	f.keys, f.short = keys, short
+	return nil
+}
+
+// Normalization returns how keys and shortkeys are normalized.
+func (f *Flags) Normalization() Normalization {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.norm
+}
+
 // Define defines a flag under specified key and optional
 // longkey with specified help and default value defval.
 // key and shortkey must be unique in Flags, shortkey is optional.
+// Subs and operations cannot be defined with Define, see DefineSub and
+// DefineOperation.
 // If a non-nil error is returned flag was not defined.
 func (f *Flags) Define(key, shortkey, help, paramhelp, defval string, typ FlagKind) (err error) {
+	if typ == KindSub || typ == KindOperation {
+		return ErrInvalid
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
 	_, err = f.define(key, shortkey, help, paramhelp, defval, typ)
 	return
 }
 
 // DefineSwitch defines an optional switch without a param.
 func (f *Flags) DefineSwitch(key, shortkey, help string) (err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
 	_, err = f.define(key, shortkey, help, "", "", KindSwitch)
 	return
 }
 
+// DefineCounter defines an optional switch without a param which may be
+// parsed more than once and counts the number of times it was parsed.
+func (f *Flags) DefineCounter(key, shortkey, help string) (err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	_, err = f.define(key, shortkey, help, "", "", KindCounter)
+	return
+}
+
 // DefineOptional defines an optional flag with a required param.
 func (f *Flags) DefineOptional(key, shortkey, help, paramhelp, defval string) (err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
 	_, err = f.define(key, shortkey, help, paramhelp, defval, KindOptional)
 	return
 }
 
+// DefineOptionalParam defines an optional flag with an optional param which
+// can only be passed inline as "--key=value", "-k=value" or "-kvalue".
+// Value returns defval if flag was parsed without a param.
+func (f *Flags) DefineOptionalParam(key, shortkey, help, paramhelp, defval string) (err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	_, err = f.define(key, shortkey, help, paramhelp, defval, KindOptionalParam)
+	return
+}
+
 // DefineRequired defines a required flag with a required param.
 func (f *Flags) DefineRequired(key, shortkey, help, paramhelp, defval string) (err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
 	_, err = f.define(key, shortkey, help, paramhelp, defval, KindRequired)
 	return
 }
@@ -209,6 +755,11 @@ func (f *Flags) DefineRequired(key, shortkey, help, paramhelp, defval string) (e
 // must be unique in these Flags. When invoken rest of params are passed to it.
 // help defines the flag help. If a non-nil error is returned flag was not defined.
 func (f *Flags) DefineSub(key, shortkey, help string, sub *Flags) error {
+	if sub == nil {
+		return ErrInvalid
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
 	flag, err := f.define(key, shortkey, help, "", "", KindSub)
 	if err != nil {
 		return err
@@ -217,16 +768,74 @@ func (f *Flags) DefineSub(key, shortkey, help string, sub *Flags) error {
 	return nil
 }
 
+// DefineOperation defines an operation under specified key and optional
+// shortkey which must be unique in these Flags, with specified help and
+// options. If a non-nil error is returned operation was not defined.
+//
+// If Flags define operations, exactly one operation must be parsed.
+// Flags defined in these Flags are global options and may be parsed before
+// and after the operation, operation options only after it. Once an
+// operation is parsed, the rest of args is parsed by its options, falling
+// back to global options. Shortkeys of operation options and global options
+// can be combined with the operation shortkey as in "-Syu".
+//
+// Operation options may reuse keys and shortkeys of options of other
+// operations but may not redefine global options. Options defined in opts
+// after the operation was defined are not checked for that.
+func (f *Flags) DefineOperation(key, shortkey, help string, opts *Flags) error {
+	if opts == nil {
+		return ErrInvalid
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	opts.mu.RLock()
+	defer opts.mu.RUnlock()
+	for _, flag := range opts.keys {
+		if g, ok := f.getkey(flag.key); ok && g.kind != KindOperation {
+			return ErrDuplicate.WrapArgs(flag.key)
+		}
+		if g, ok := f.getshortkey(flag.shortkey); flag.shortkey != "" && ok && g.kind != KindOperation {
+			return ErrDupShort.WrapArgs(flag.shortkey)
+		}
+	}
+	flag, err := f.define(key, shortkey, help, "", "", KindOperation)
+	if err != nil {
+		return err
+	}
+	flag.sub = opts
+	return nil
+}
+
+// Operation returns the operation parsed at last Parse or nil if none.
+func (f *Flags) Operation() *Flag {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.result.operation()
+}
+
+// hasoperations returns if Flags define any operations.
+func (f *Flags) hasoperations() bool {
+	for _, flag := range f.keys {
+		if flag.kind == KindOperation {
+			return true
+		}
+	}
+	return false
+}
+
 // SetExclusive sets specified keys as mutually exclusive in Flags.
 // If more than one key from exclusive group are parsed, parse will error.
 // Keys must already be defined.
-// Subsequent calls redefine exclusivity.
+// Subsequent calls redefine exclusivity. To define multiple exclusive
+// groups use AddConstraint with Exclusive.
 func (f *Flags) SetExclusive(keys ...string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
 	for _, flag := range f.keys {
 		flag.excl = false
 	}
 	for _, key := range keys {
-		flag, ok := f.GetKey(key)
+		flag, ok := f.getkey(key)
 		if !ok {
 			return ErrNotFound.WrapArgs(key)
 		}
@@ -237,56 +846,260 @@ func (f *Flags) SetExclusive(keys ...string) error {
 
 // GetKey returns Flag if under specified key and a truth if it exists.
 func (f *Flags) GetKey(key string) (flag *Flag, truth bool) {
-	flag, truth = f.keys[key]
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.getkey(key)
+}
+
+// getkey is GetKey for callers holding the lock.
+func (f *Flags) getkey(key string) (flag *Flag, truth bool) {
+	flag, truth = f.keys[f.normkey(key)]
 	return
 }
 
+// getnegated returns a negatable Flag addressed by a negated key and a truth
+// if it exists.
+func (f *Flags) getnegated(key string) (*Flag, bool) {
+	key = f.normkey(key)
+	if !strings.HasPrefix(key, "no-") {
+		return nil, false
+	}
+	if flag, ok := f.getkey(strings.TrimPrefix(key, "no-")); ok && flag.negatable && flag.kind == KindSwitch {
+		return flag, true
+	}
+	return nil, false
+}
+
+// findlong finds a flag by long key, a negated long key or, if abbrev is
+// true and abbreviations are enabled, by a unique prefix of either. It
+// returns the flag or nil if not found and if key negates it. If key is a
+// prefix of more than one key an ErrAmbiguous listing keys prefixed with
+// long is returned.
+func (f *Flags) findlong(key, long string, abbrev bool) (flag *Flag, negated bool, err error) {
+	var ok bool
+	if flag, ok = f.getkey(key); ok {
+		return flag, false, nil
+	}
+	if flag, ok = f.getnegated(key); ok {
+		return flag, true, nil
+	}
+	if !abbrev || !f.abbrev || key == "" {
+		return nil, false, nil
+	}
+	var candidates []string
+	prefix := f.normkey(key)
+	for k, v := range f.keys {
+		if strings.HasPrefix(k, prefix) {
+			candidates = append(candidates, long+v.key)
+			flag, negated = v, false
+		}
+		if v.negatable && strings.HasPrefix(f.normkey("no-"+k), prefix) {
+			candidates = append(candidates, long+"no-"+v.key)
+			flag, negated = v, true
+		}
+	}
+	switch len(candidates) {
+	case 0:
+		return nil, false, nil
+	case 1:
+		return flag, negated, nil
+	}
+	sort.Strings(candidates)
+	return nil, false, ErrAmbiguous.WrapArgs(long+key, strings.Join(candidates, ", "))
+}
+
 // GetShort returns Flag under specified shortkey and a truth if it exists.
 func (f *Flags) GetShort(shortkey string) (flag *Flag, truth bool) {
-	return f.GetKey(f.short[shortkey])
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.getshortkey(shortkey)
+}
+
+// getshortkey is GetShort for callers holding the lock.
+func (f *Flags) getshortkey(shortkey string) (flag *Flag, truth bool) {
+	var key string
+	if key, truth = f.short[f.normshort(shortkey)]; !truth {
+		return nil, false
+	}
+	return f.getkey(key)
+}
+
+// getshort returns Flag under specified shortkey and a truth if it exists.
+// If parsing operation options and Flag does not exist, global options are
+// searched.
+func (f *Flags) getshort(shortkey string, opts parseopts) (*Flag, bool) {
+	if flag, ok := f.getshortkey(shortkey); ok {
+		return flag, ok
+	}
+	if opts.op != nil {
+		return opts.op.flags.getshortkey(shortkey)
+	}
+	return nil, false
+}
+
+// getlong is findlong using opts syntax which, if parsing operation options
+// and Flag does not exist, searches global options.
+func (f *Flags) getlong(key string, abbrev bool, opts parseopts) (*Flag, bool, error) {
+	flag, negated, err := f.findlong(key, opts.syntax.Long, abbrev)
+	if flag == nil && err == nil && opts.op != nil {
+		return opts.op.flags.findlong(key, opts.syntax.Long, abbrev)
+	}
+	return flag, negated, err
+}
+
+// matchshort returns true if key, possibly followed by an inline value,
+// addresses a flag by shortkey or combined shortkeys. It is used to prefer
+// shortkeys over abbreviated long keys if their prefixes are equal.
+func (f *Flags) matchshort(key string, opts parseopts) bool {
+	name, _, _ := opts.syntax.split(key)
+	if f.matchcombined(name, opts) || f.matchcombined(key, opts) {
+		return true
+	}
+	if _, ok := f.getshort(name, opts); ok {
+		return true
+	}
+	_, ok := f.getshort(key, opts)
+	return ok
 }
 
 // GetValue will return current value of a key, if found.
 // Returns an empty string otherwise.
 // Check before if key was parsed with Parsed().
 func (f *Flags) GetValue(key string) string {
-	if flag, exists := f.GetKey(key); exists {
-		return flag.Value()
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	if flag, exists := f.getkey(key); exists {
+		return flag.valueof(flag.state())
 	}
 	return ""
 }
 
-// reset resets values and parsed states of self and any subs.
-func (f *Flags) reset() {
+// SetParseMode sets how Parse handles flags following operands.
+// Subs with ModeDefault parse mode use the parse mode of these Flags.
+func (f *Flags) SetParseMode(mode ParseMode) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.mode = mode
+}
+
+// ParseMode returns how Parse handles flags following operands.
+func (f *Flags) ParseMode() ParseMode {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.mode
+}
+
+// SetSyntax sets flag prefixes, inline value separators and terminator used
+// when parsing and printing these Flags. Long and Short prefixes must not be
+// empty. Subs with no syntax set use the syntax of these Flags.
+// Default is DefaultSyntax.
+func (f *Flags) SetSyntax(syntax Syntax) error {
+	if syntax.Long == "" || syntax.Short == "" {
+		return ErrInvalid
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.syntax = syntax
+	return nil
+}
+
+// Syntax returns syntax set on Flags or an empty Syntax if not set.
+func (f *Flags) Syntax() Syntax {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.syntax
+}
+
+// SetAbbreviations sets if long keys of flags in these Flags can be
+// abbreviated to any unique prefix of the key when parsing.
+// Subs use their own setting.
+func (f *Flags) SetAbbreviations(abbrev bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.abbrev = abbrev
+}
+
+// Abbreviations returns if long keys can be abbreviated when parsing.
+func (f *Flags) Abbreviations() bool {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.abbrev
+}
+
+// SetUnknownPolicy sets how Parse handles args that address no defined flags
+// in these Flags. Subs use their own policy. Default is UnknownError.
+func (f *Flags) SetUnknownPolicy(policy UnknownPolicy) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.unknownpolicy = policy
+}
+
+// UnknownPolicy returns how Parse handles args that address no defined flags.
+func (f *Flags) UnknownPolicy() UnknownPolicy {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.unknownpolicy
+}
+
+// Unknown returns args addressing no defined flags and their params that were
+// collected at last Parse in order of appearance if unknown policy is
+// UnknownPass. If a sub was parsed, unknown args following it are returned by
+// sub's Unknown.
+func (f *Flags) Unknown() []string {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.result.unknown
+}
+
+// Args returns operands collected at last Parse in order of appearance.
+// If a sub was parsed, operands following it are returned by sub's Args.
+func (f *Flags) Args() []string {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.result.args
+}
+
+// apply sets r as the result of last Parse of Flags and results of its subs
+// as results of last Parse of sub Flags. Subs not parsed in r get an empty
+// result.
+func (f *Flags) apply(r *Result) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.result = r
 	for _, flag := range f.keys {
-		flag.parsed = false
-		flag.parsedval = false
-		flag.value = ""
-		if flag.sub != nil {
-			flag.sub.reset()
+		if flag.sub != nil && flag != r.subflag {
+			flag.sub.apply(newresult(flag.sub, r))
 		}
 	}
-	f.parsed = false
+	if r.subflag != nil {
+		r.subflag.sub.apply(r.sub)
+	}
 }
 
 // matchcombined matches a possibly multilevel combined key against defined Flags.
 // It does so by matching each consecutive char in arg with a defined shortkey.
 // If no defined flag under current shortkey, checks for a match in a sub, if any.
+// An operation shortkey may be anywhere in arg, in which case the rest of arg
+// is matched against operation options and global options.
 // Returns true if whole arg was matched, no matter its length and sub span.
-func (f *Flags) matchcombined(arg string) bool {
+// Caller must hold the read lock, Flags of subs are read locked while matched.
+func (f *Flags) matchcombined(arg string, opts parseopts) bool {
 	if arg == "" {
 		return false
 	}
 	var flag *Flag
 	var ok bool
 	for i := 0; i < len(arg); i++ {
-		flag, ok = f.GetShort(string(arg[i]))
+		flag, ok = f.getshort(string(arg[i]), opts)
 		if ok {
+			if flag.kind == KindOperation {
+				return i == len(arg)-1 || flag.sub.rmatchcombined(arg[i+1:], parseopts{op: flag})
+			}
 			if flag.sub != nil {
 				if i == len(arg)-1 {
 					return false
 				}
-				return flag.sub.matchcombined(arg[i+1:])
+				return flag.sub.rmatchcombined(arg[i+1:], parseopts{})
 			}
 			continue
 		}
@@ -295,263 +1108,794 @@ func (f *Flags) matchcombined(arg string) bool {
 	return true
 }
 
-// findflag finds a flag by key or shortkey from arg and
-// returns it if found and truth if exists.
-func (f *Flags) findflag(arg string) (*Flag, bool) {
-	var flag *Flag
-	var ok bool
-	if strings.HasPrefix(arg, "-") {
-		key := strings.TrimPrefix(arg, "-")
-		if f.matchcombined(key) {
-			flag, ok = f.GetShort(string(key[0]))
-		} else {
-			flag, ok = f.GetShort(key)
+// rmatchcombined is matchcombined which read locks Flags.
+func (f *Flags) rmatchcombined(arg string, opts parseopts) bool {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.matchcombined(arg, opts)
+}
+
+// findflag finds a flag by key or shortkey from arg using opts and returns
+// it if found and truth if exists.
+//
+// arg addresses a flag if it is a long key, a negated long key or an
+// abbreviation of either optionally followed by an inline value, a
+// shortkey, combined shortkeys optionally followed by an inline value, a
+// shortkey followed by an attached value or a negated switch.
+func (f *Flags) findflag(arg string, opts parseopts) (*Flag, bool) {
+	syntax := opts.syntax
+	if syntax.islong(arg) {
+		key, _, _ := syntax.split(strings.TrimPrefix(arg, syntax.Long))
+		flag, _, err := f.getlong(key, syntax.Long != syntax.Short, opts)
+		if flag != nil || err != nil {
+			return flag, true
 		}
-		if ok {
+		if syntax.Long != syntax.Short {
+			return nil, false
+		}
+		if !f.matchshort(strings.TrimPrefix(arg, syntax.Short), opts) {
+			if flag, _, err = f.getlong(key, true, opts); flag != nil || err != nil {
+				return flag, true
+			}
+		}
+	}
+	if strings.HasPrefix(arg, syntax.Short) {
+		key := strings.TrimPrefix(arg, syntax.Short)
+		if key == "" {
+			return nil, false
+		}
+		name, _, _ := syntax.split(key)
+		if f.matchcombined(name, opts) || f.matchcombined(key, opts) {
+			return f.getshort(string(key[0]), opts)
+		}
+		if flag, ok := f.getshort(name, opts); ok {
 			return flag, ok
 		}
-
-		if strings.HasPrefix(key, "-") {
-			flag, ok = f.GetKey(strings.TrimPrefix(key, "-"))
+		if flag, ok := f.getshort(key, opts); ok {
+			return flag, ok
 		}
+		return f.getshort(string(key[0]), opts)
 	}
-	if !ok {
-		return nil, false
+	if syntax.Negate != "" && strings.HasPrefix(arg, syntax.Negate) {
+		key := strings.TrimPrefix(arg, syntax.Negate)
+		if flag, _, _ := f.getlong(key, true, opts); flag != nil {
+			return flag, true
+		}
+		if key != "" && f.matchcombined(key, opts) {
+			return f.getshort(string(key[0]), opts)
+		}
 	}
-	return flag, true
+	return nil, false
 }
 
-// consume marks a flag as parsed and sets its value if not empty.
-func (f *Flags) consume(key, value string) error {
+// consume marks flag as parsed from arg currently being parsed in the
+// Result of Flags defining it and sets its value if not empty.
+func (r *Result) consume(flag *Flag, value string) error {
+	return r.consumefrom(flag, value, Source{Kind: SourceArgs, Index: r.cur})
+}
 
-	flag, ok := f.keys[key]
-	if !ok {
-		return ErrNotFound.WrapArgs(key)
+// consumefrom marks flag as parsed from source in the Result of Flags
+// defining it, which is r or one of its parents, and sets its value if not
+// empty.
+func (r *Result) consumefrom(flag *Flag, value string, source Source) error {
+	fail := func(err error) error {
+		if source.Kind == SourceArgs {
+			return r.argerror(err, flag)
+		}
+		return r.flagerror(err, flag)
 	}
-	if flag.Parsed() {
-		return ErrDuplicate.WrapArgs(key)
+	owner := r
+	for owner.flags != flag.flags {
+		owner = owner.parent
 	}
-	if flag.Excl() {
-		for _, v := range f.keys {
-			if v.Parsed() && v.Excl() {
-				return ErrExclusive.WrapArgs(v.Key(), key)
+	s := owner.state(flag)
+	override := flag.negatable || r.negate && flag.kind == KindSwitch
+	if s.parsed && !flag.repeatable && !override && flag.kind != KindCounter {
+		return fail(ErrDuplicate.WrapArgs(flag.key))
+	}
+	if flag.excl {
+		for _, v := range owner.flags.keys {
+			if v != flag && owner.get(v).parsed && v.excl {
+				return fail(ErrExclusive.WrapArgs(v.key, flag.key))
 			}
 		}
 	}
-	flag.parsed = true
 	if value != "" {
-		flag.value = value
-		flag.parsedval = true
+		var err error
+		if value, err = flag.process(value); err != nil {
+			return fail(err)
+		}
+	}
+	s.parsed = true
+	s.count++
+	s.source = source
+	if flag.kind == KindSwitch {
+		s.value, s.parsedval = value, value != ""
+		return nil
+	}
+	if value != "" {
+		s.value = value
+		s.values = append(s.values, flag.split(value)...)
+		s.parsedval = true
 	}
 	return nil
 }
 
-// splitcombined splits combined shortkeys into multiple shortkeys.
-func splitcombined(arg string) []string {
-	a := strings.Split(arg, "")
-	for i := 0; i < len(a); i++ {
-		a[i] = "-" + a[i]
+// peek returns index of the first non-empty arg in args after i or -1 if
+// there are no more non-empty args.
+func peek(args []string, i int) int {
+	for i++; i < len(args); i++ {
+		if strings.TrimSpace(args[i]) != "" {
+			return i
+		}
 	}
-	return a
+	return -1
 }
 
 // Parse parses specified args.
+//
+// Args which are neither flags nor flag params are operands and are collected
+// in order of appearance. An arg "--" terminates flag parsing and all args
+// following it are treated as operands. A bare "-", commonly standing for
+// stdin, is an operand. Operands are trimmed of surrounding whitespace and
+// empty args are skipped. Operands are retrievable with Args.
+//
+// A param can be passed to a flag as the arg following it or inline as
+// "--key=value", "-k=value" or "-kvalue". A switch accepts only an inline
+// boolean param and a negatable switch can be negated as "--no-key".
+// Shortkeys of flags may be combined as "-abc" where all but the last flag
+// must be switches and the last one may be given a param inline as
+// "-abc=value" or as the following arg. A sub shortkey may only be first in
+// combined shortkeys in which case the rest of the combined shortkeys is
+// passed to the sub.
+//
+// When a sub flag is parsed the rest of args are passed to its Flags which
+// collects its own operands.
+// When an operation is parsed the rest of args are parsed by its options
+// and global options, see DefineOperation.
+//
+// How flags following operands are handled is specified by ParseMode.
+// Args naming args files are expanded if enabled with SetArgsFiles.
+// Flags not parsed from args take values of environment variables bound to
+// them, if any, see SetEnvPrefix and Flag.SetEnv, and then values from a
+// config file, if any, see SetConfig. Constraints on flags are checked once
+// all of them were given values, see AddConstraint.
+// Prefixes, separators and terminator used above are those of DefaultSyntax
+// and can be changed with SetSyntax.
+// Parse returns a *ParseError describing where parsing failed or, if errors
+// are collected, all errors that occurred, see SetCollectErrors.
+//
+// Parse stores the result of parsing in Flags, retrievable with Flags, Flag
+// and Positional accessors until the next Parse, see Evaluate.
 func (f *Flags) Parse(args []string) error {
-	f.reset()
-	var flag *Flag
-	var ok, comb bool
-	var saved string
+	r, err := f.evaluate(args)
+	f.apply(r)
+	return err
+}
+
+// Evaluate parses args as Parse does but returns the result of parsing as a
+// Result instead of storing it in Flags. Flags and their flags are not
+// modified and may be evaluated from multiple goroutines at once.
+// If an error occurs Result is nil.
+func (f *Flags) Evaluate(args []string) (*Result, error) {
+	r, err := f.evaluate(args)
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// evaluate parses args into a new Result which is returned along with
+// parse error, if any, in which case Result holds args parsed before it.
+func (f *Flags) evaluate(args []string) (*Result, error) {
+	r := newresult(f, nil)
+	r.collect = f.CollectErrors()
+	opts := parseopts{mode: ModeInterspersed, syntax: DefaultSyntax}
+	err := r.parse(args, opts)
+	if err == nil {
+		err = r.finish(opts, nil)
+	}
+	if !r.collect || (err == nil && len(r.errs) == 0) {
+		return r, r.flagerror(err, nil)
+	}
+	if err != nil {
+		r.errs = append(r.errs, r.flagerror(err, nil).(*ParseError))
+	}
+	for v := r; v != nil; v = v.sub {
+		v.parsed = false
+	}
+	return r, ParseErrors(r.errs)
+}
+
+// parseopts holds parse options which subs inherit from their parent and
+// the operation whose options are being parsed, if any.
+type parseopts struct {
+	mode      ParseMode
+	syntax    Syntax
+	op        *Flag
+	argsfiles bool
+	envprefix string
+}
+
+// subopts returns parse options passed to Flags of sub or operation flag.
+func (opts parseopts) subopts(flag *Flag) parseopts {
+	opts.op = nil
+	if flag.kind == KindOperation {
+		opts.op = flag
+	}
+	if opts.envprefix != "" {
+		opts.envprefix += "_" + envkey(flag.key)
+	}
+	return opts
+}
+
+// opts returns effective parse options of Flags given parent options.
+func (f *Flags) opts(parent parseopts) parseopts {
+	opts := parent
+	if f.mode != ModeDefault {
+		opts.mode = f.mode
+	}
+	if opts.mode == ModeEnv {
+		opts.mode = ModeInterspersed
+		if _, ok := os.LookupEnv("POSIXLY_CORRECT"); ok {
+			opts.mode = ModePOSIX
+		}
+	}
+	if f.syntax != (Syntax{}) {
+		opts.syntax = f.syntax
+	}
+	opts.argsfiles = opts.argsfiles || f.argsfiles
+	if f.envprefix != "" {
+		opts.envprefix = f.envprefix
+	}
+	return opts
+}
+
+// parse parses args using Flags parse options or parent parse options
+// where Flags options are not set.
+func (r *Result) parse(args []string, parent parseopts) error {
+	f := r.flags
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	opts := f.opts(parent)
+	r.negate = opts.syntax.Negate != ""
+	var sub bool
 	var arg string
+	var err error
+	if r.idx == nil {
+		r.idx = make([]int, len(args))
+		for i := range r.idx {
+			r.idx[i] = i
+		}
+	}
+	if opts.argsfiles && !parent.argsfiles {
+		var idx []int
+		if args, idx, err = expandargs(args, opts.syntax.Terminator); err != nil {
+			return r.flagerror(err, nil)
+		}
+		for i, j := range idx {
+			idx[i] = r.idx[j]
+		}
+		r.idx = idx
+	}
+	// operands is set once flag parsing is terminated.
+	var operands bool
 	for i := 0; i < len(args); i++ {
 		arg = strings.TrimSpace(args[i])
 		if arg == "" {
 			continue
 		}
-		flag, ok = f.findflag(arg)
+		r.cur, r.token = r.idx[i], arg
+		if operands {
+			r.args, r.argidx = append(r.args, arg), append(r.argidx, r.cur)
+			continue
+		}
+		if opts.syntax.Terminator != "" && arg == opts.syntax.Terminator {
+			operands = true
+			continue
+		}
+		if !opts.syntax.isflag(arg) {
+			r.args, r.argidx = append(r.args, arg), append(r.argidx, r.cur)
+			operands = opts.mode == ModePOSIX
+			continue
+		}
+		switch {
+		case opts.syntax.islong(arg):
+			i, sub, err = r.parselong(args, i, opts)
+		case strings.HasPrefix(arg, opts.syntax.Short):
+			i, sub, err = r.parseshort(args, i, opts)
+		default:
+			i, err = r.parsenegated(args, i, opts)
+		}
+		if err = r.fail(r.argerror(err, nil)); err != nil {
+			return err
+		}
+		if sub {
+			break
+		}
+	}
 
-		if !ok {
-			if saved == "" {
-				saved = arg
-				continue
+	return nil
+}
+
+// finish finishes parsing of Result and Results of its parsed subs once all
+// args were parsed. Flags not parsed from args fall back to environment and
+// then to config values which are loaded from config file by root Result.
+// Positionals are bound and required flags checked after parsed subs are
+// finished.
+func (r *Result) finish(parent parseopts, config map[string]interface{}) error {
+	f := r.flags
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	opts := f.opts(parent)
+	var err error
+	if err = r.parseenv(opts); err != nil {
+		return err
+	}
+	if r.parent == nil {
+		if config, r.configfile, err = r.loadconfig(); err != nil {
+			if err = r.fail(r.flagerror(err, nil)); err != nil {
+				return err
 			}
-			flag, ok = f.findflag(saved)
-			if !ok {
-				return ErrNotFound.WrapArgs(saved)
+		}
+	}
+	if config, err = r.parseconfig(config); err != nil {
+		return err
+	}
+	if err = r.imply(); err != nil {
+		return err
+	}
+	if r.sub != nil {
+		if err = r.sub.finish(opts.subopts(r.subflag), config); err != nil {
+			return err
+		}
+	}
+
+	// Check if required and any parsed.
+	noparse := len(r.args) == 0 && !r.foreign
+	for _, flag := range f.sorted() {
+		if flag.kind == KindRequired && !r.get(flag).parsed {
+			if err = r.fail(r.flagerror(ErrRequired.WrapArgs(flag.key), flag)); err != nil {
+				return err
 			}
-			saved = strings.TrimPrefix(saved, "-")
-			comb = f.matchcombined(saved)
-			if flag.Sub() != nil {
-				flag.parsed = true
-				if !comb && i == len(args)-1 {
-					return ErrSub.WrapArgs(flag.Key())
-				}
-				if comb {
-					return flag.sub.Parse(append(splitcombined(saved[1:]), args[i:]...))
+		}
+		if r.get(flag).parsed {
+			noparse = false
+		}
+		if !r.get(flag).parsedval && flag.defval != "" {
+			if _, err = flag.process(flag.defval); err != nil {
+				if err = r.fail(r.flagerror(err, flag)); err != nil {
+					return err
 				}
-				return flag.sub.Parse(args[i:])
 			}
-			if flag.Kind() == KindSwitch {
-				if len(saved) > 1 {
-					return ErrNotSub.WrapArgs(flag.Shortkey())
-				}
-				return ErrSwitch.WrapArgs(flag.Key())
+		}
+	}
+	if err = r.checkconstraints(); err != nil {
+		return err
+	}
+	if err = r.bindpositionals(); err != nil {
+		return err
+	}
+	if noparse && opts.op == nil && len(r.root().errs) == 0 {
+		if err = r.fail(r.flagerror(ErrNoArgs, nil)); err != nil {
+			return err
+		}
+	}
+	if f.hasoperations() && r.operation() == nil {
+		if err = r.fail(r.flagerror(ErrNoOperation, nil)); err != nil {
+			return err
+		}
+	}
+	r.parsed = true
+	return nil
+}
+
+// parselong parses a long key arg at index i in args. If long key prefix
+// equals shortkey prefix, arg is matched against long keys, then shortkeys
+// and then abbreviated long keys and is parsed as a shortkey if no flag is
+// found.
+// It returns index of last arg consumed and if a sub consumed the rest of
+// args.
+func (r *Result) parselong(args []string, i int, opts parseopts) (int, bool, error) {
+	f := r.flags
+	arg := strings.TrimSpace(args[i])
+	key, value, inline := opts.syntax.split(strings.TrimPrefix(arg, opts.syntax.Long))
+	shared := opts.syntax.Long == opts.syntax.Short
+	flag, negated, err := f.getlong(key, !shared, opts)
+	if flag == nil && shared {
+		if f.matchshort(strings.TrimPrefix(arg, opts.syntax.Short), opts) {
+			return r.parseshort(args, i, opts)
+		}
+		flag, negated, err = f.getlong(key, true, opts)
+	}
+	if err != nil {
+		return i, false, err
+	}
+	if flag == nil {
+		if shared {
+			return r.parseshort(args, i, opts)
+		}
+		i, err = r.parseunknown(args, i, opts.syntax.Long+key, opts)
+		return i, false, err
+	}
+	if negated {
+		if inline {
+			return i, false, r.argerror(ErrSwitch.WrapArgs(flag.key), flag)
+		}
+		return i, false, r.consume(flag, "false")
+	}
+	if flag.sub != nil {
+		if inline {
+			return i, false, r.argerror(ErrSwitch.WrapArgs(flag.key), flag)
+		}
+		return i, true, r.parsesub(flag, args, i, "", opts)
+	}
+	i, err = r.parsevalue(flag, opts.syntax.Long+key, value, inline, args, i, opts)
+	return i, false, err
+}
+
+// parseshort parses a shortkey arg at index i in args, possibly combined and
+// possibly with an inline or attached value.
+// It returns index of last arg consumed and if a sub consumed the rest of
+// args.
+func (r *Result) parseshort(args []string, i int, opts parseopts) (int, bool, error) {
+	f := r.flags
+	arg := strings.TrimSpace(args[i])
+	key := strings.TrimPrefix(arg, opts.syntax.Short)
+	name, value, inline := opts.syntax.split(key)
+
+	// Combined shortkeys, optionally with an inline value.
+	if len(name) > 1 && f.matchcombined(name, opts) {
+		return r.parsecombined(args, i, key, name, value, inline, opts)
+	}
+	if len(key) > 1 && f.matchcombined(key, opts) {
+		return r.parsecombined(args, i, key, key, "", false, opts)
+	}
+	// Shortkey, optionally with an inline value.
+	flag, ok := f.getshort(name, opts)
+	if !ok && inline {
+		flag, ok = f.getshort(key, opts)
+		name, value, inline = key, "", false
+	}
+	if ok {
+		if flag.sub != nil {
+			if inline {
+				return i, false, r.argerror(ErrSwitch.WrapArgs(flag.key), flag)
 			}
-			if err := f.consume(flag.Key(), arg); err != nil {
-				return err
+			return i, true, r.parsesub(flag, args, i, "", opts)
+		}
+		i, err := r.parsevalue(flag, opts.syntax.Short+name, value, inline, args, i, opts)
+		return i, false, err
+	}
+	// Shortkeys with an attached value.
+	if !f.matchattached(key, opts) {
+		i, err := r.parseunknown(args, i, arg, opts)
+		return i, false, err
+	}
+	for j := 0; j < len(key); j++ {
+		flag, _ = f.getshort(key[j:j+1], opts)
+		if flag.sub != nil {
+			if j > 0 && flag.kind != KindOperation {
+				first, _ := f.getshort(key[:1], opts)
+				return i, false, r.argerror(ErrNotSub.WrapArgs(first.key), first)
 			}
-			saved = ""
-			continue
+			return i, true, r.parsesub(flag, args, i, key[j+1:], opts)
 		}
-
-		if saved == "" {
-			if flag.sub != nil {
-				flag.parsed = true
-				arg = strings.TrimPrefix(arg, "-")
-				comb = f.matchcombined(arg)
-				if !comb && i == len(args)-1 {
-					return ErrSub.WrapArgs(flag.Key())
-				}
-				if comb {
-					return flag.sub.Parse(append(splitcombined(arg[1:]), args[i+1:]...))
-				}
-				return flag.sub.Parse(args[i+1:])
+		if flag.isswitch() {
+			if err := r.consume(flag, ""); err != nil {
+				return i, false, err
 			}
-			saved = args[i]
 			continue
 		}
+		i, err := r.parsevalue(flag, opts.syntax.Short+key[j:j+1], key[j+1:], true, args, i, opts)
+		return i, false, err
+	}
+	return i, false, nil
+}
 
-		flag, ok = f.findflag(saved)
-		if !ok {
-			return ErrNotFound.WrapArgs(saved)
+// parsenegated parses a negated long key or negated, possibly combined,
+// shortkeys of switches at index i in args.
+// It returns index of last arg consumed.
+func (r *Result) parsenegated(args []string, i int, opts parseopts) (int, error) {
+	f := r.flags
+	arg := strings.TrimSpace(args[i])
+	key := strings.TrimPrefix(arg, opts.syntax.Negate)
+	flag, negated, err := f.getlong(key, true, opts)
+	if err != nil {
+		return i, err
+	}
+	if flag != nil && !negated {
+		if flag.kind != KindSwitch {
+			return i, r.argerror(ErrNegate.WrapArgs(flag.key), flag)
 		}
-		if flag.Kind() == KindRequired {
-			return ErrReqVal.WrapArgs(saved)
+		return i, r.consume(flag, "false")
+	}
+	if key == "" || !f.matchcombined(key, opts) {
+		return r.parseunknown(args, i, arg, opts)
+	}
+	for j := 0; j < len(key); j++ {
+		if flag, _ = f.getshort(key[j:j+1], opts); flag.kind != KindSwitch {
+			return i, r.argerror(ErrNegate.WrapArgs(flag.key), flag)
 		}
-		if err := f.consume(flag.Key(), ""); err != nil {
-			return err
+	}
+	for j := 0; j < len(key); j++ {
+		flag, _ = f.getshort(key[j:j+1], opts)
+		if err = r.consume(flag, "false"); err != nil {
+			return i, err
 		}
-		saved = arg
 	}
+	return i, nil
+}
 
-	// Check remaining saved arg.
-	if saved != "" {
-		flag, ok := f.findflag(saved)
+// matchattached returns true if key consists of zero or more switch
+// shortkeys followed by a sub shortkey or a shortkey of a flag that takes a
+// param, followed by anything.
+func (f *Flags) matchattached(key string, opts parseopts) bool {
+	for j := 0; j < len(key); j++ {
+		flag, ok := f.getshort(key[j:j+1], opts)
 		if !ok {
-			return ErrNotFound.WrapArgs(saved)
+			return false
 		}
-		if flag.Kind() == KindRequired {
-			return ErrReqVal.WrapArgs(saved)
+		if !flag.isswitch() {
+			return true
 		}
-		saved = strings.TrimPrefix(saved, "-")
-		comb = f.matchcombined(saved)
-		if flag.Sub() != nil {
-			flag.parsed = true
-			if !comb {
-				return ErrSub.WrapArgs(flag.Key())
-			}
-			return flag.sub.Parse(splitcombined(saved[1:]))
+	}
+	return false
+}
+
+// parseunknown handles arg at index i in args which addresses no defined
+// flag according to Flags unknown policy. Unless arg has an inline value,
+// the following arg is considered a param to arg if it is not prefixed as a
+// flag. It returns index of last arg consumed.
+func (r *Result) parseunknown(args []string, i int, arg string, opts parseopts) (int, error) {
+	f := r.flags
+	if f.unknownpolicy == UnknownError {
+		return i, f.notfound(arg, opts)
+	}
+	r.foreign = true
+	j := i
+	if _, _, inline := opts.syntax.split(args[i]); !inline {
+		if n := peek(args, i); n >= 0 && !opts.syntax.isflag(strings.TrimSpace(args[n])) {
+			j = n
+		}
+	}
+	if f.unknownpolicy == UnknownPass {
+		r.unknown = append(r.unknown, strings.TrimSpace(args[i]))
+		if j > i {
+			r.unknown = append(r.unknown, strings.TrimSpace(args[j]))
 		}
-		if flag.Kind() == KindSwitch {
-			if comb && len(saved) > 1 {
-				return ErrNotSub.WrapArgs(flag.Key())
+	}
+	return j, nil
+}
+
+// parsecombined parses combined shortkeys name from key at index i in args.
+// If inline, last shortkey in name is given value.
+// It returns index of last arg consumed and if a sub consumed the rest of
+// args.
+func (r *Result) parsecombined(args []string, i int, key, name, value string, inline bool, opts parseopts) (int, bool, error) {
+	f := r.flags
+	var flag *Flag
+	var err error
+	for j := 0; j < len(name); j++ {
+		flag, _ = f.getshort(name[j:j+1], opts)
+		if flag.sub != nil {
+			if j > 0 && flag.kind != KindOperation {
+				first, _ := f.getshort(name[:1], opts)
+				return i, false, r.argerror(ErrNotSub.WrapArgs(first.key), first)
 			}
+			return i, true, r.parsesub(flag, args, i, key[j+1:], opts)
 		}
-		if err := f.consume(flag.Key(), ""); err != nil {
-			return err
+		if j < len(name)-1 {
+			if _, err = r.parsevalue(flag, opts.syntax.Short+name[j:j+1], "", false, nil, -1, opts); err != nil {
+				return i, false, err
+			}
+			continue
 		}
+		i, err = r.parsevalue(flag, opts.syntax.Short+name[j:j+1], value, inline, args, i, opts)
 	}
+	return i, false, err
+}
 
-	// Check if required and any parsed.
-	noparse := true
-	for _, flag = range f.keys {
-		if flag.Kind() == KindRequired && !flag.Parsed() {
-			return ErrRequired.WrapArgs(flag.Key())
+// parsesub marks a sub or operation flag addressed by arg at index i in
+// args as parsed and passes args following it, prefixed with remaining
+// combined shortkeys if any, to its Flags along with parse options of these
+// Flags. Unlike a sub, an operation may be given no args.
+func (r *Result) parsesub(flag *Flag, args []string, i int, remaining string, opts parseopts) error {
+	if flag.kind == KindOperation && opts.op != nil {
+		return r.argerror(ErrOperation.WrapArgs(flag.key, opts.op.key), flag)
+	}
+	s := r.state(flag)
+	s.parsed = true
+	s.count++
+	s.source = Source{Kind: SourceArgs, Index: r.cur}
+	r.sub, r.subflag = newresult(flag.sub, r), flag
+	args, r.sub.idx = args[i+1:], r.idx[i+1:]
+	if remaining != "" {
+		args = append([]string{opts.syntax.Short + remaining}, args...)
+		r.sub.idx = append([]int{r.idx[i]}, r.sub.idx...)
+	}
+	if flag.kind != KindOperation && peek(args, -1) < 0 {
+		return r.argerror(ErrSub.WrapArgs(flag.key), flag)
+	}
+	return r.sub.parse(args, opts.subopts(flag))
+}
+
+// parsevalue consumes flag addressed by arg with value if inline or the
+// param from arg following index i in args, if any. It returns index of last
+// arg consumed.
+func (r *Result) parsevalue(flag *Flag, arg, value string, inline bool, args []string, i int, opts parseopts) (int, error) {
+	f := r.flags
+	if flag.isswitch() {
+		if !inline {
+			return i, r.consume(flag, "")
 		}
-		if flag.Parsed() {
-			noparse = false
+		b, err := strconv.ParseBool(value)
+		if err != nil || flag.kind != KindSwitch {
+			return i, r.argerror(ErrSwitch.WrapArgs(flag.key), flag)
 		}
+		return i, r.consume(flag, strconv.FormatBool(b))
 	}
-	if noparse {
-		return ErrNoArgs
+	if !inline && flag.kind != KindOptionalParam {
+		if n := peek(args, i); n >= 0 && f.isparam(args[n], opts) {
+			value, i = strings.TrimSpace(args[n]), n
+		}
 	}
-	f.parsed = true
-	return nil
+	if value == "" && flag.kind == KindRequired {
+		return i, r.argerror(ErrReqVal.WrapArgs(arg), flag)
+	}
+	return i, r.consume(flag, value)
 }
 
-// printindent prints flags to w indented with indent.
-func (f *Flags) printindent(w io.Writer, indent string) {
+// isparam returns if arg can be a param to a flag.
+// Any arg except the terminator that does not address a defined flag can
+// be a param.
+func (f *Flags) isparam(arg string, opts parseopts) bool {
+	arg = strings.TrimSpace(arg)
+	if opts.syntax.Terminator != "" && arg == opts.syntax.Terminator {
+		return false
+	}
+	_, ok := f.findflag(arg, opts)
+	return !ok
+}
 
+// sorted returns Flags flags sorted by key.
+func (f *Flags) sorted() []*Flag {
 	flags := make([]*Flag, 0, len(f.keys))
 	for _, flag := range f.keys {
 		flags = append(flags, flag)
 	}
 	sort.Slice(flags, func(i, j int) bool { return flags[i].key < flags[j].key })
+	return flags
+}
 
-	for _, flag := range flags {
-		val := flag.Key()
-		if flag.paramhelp != "" {
-			val = fmt.Sprintf("%s <%s>", val, flag.paramhelp)
-		}
-		if flag.Shortkey() == "" {
-			fmt.Fprintf(w, "%s%s\t--%s\t%s\t\n", indent, "", val, flag.Help())
+// printindent prints flags to w indented with indent using Flags options or
+// parent options where Flags options are not set. Caller must hold the read
+// lock.
+func (f *Flags) printindent(w io.Writer, indent string, parent parseopts) {
+	opts := f.opts(parent)
+	for _, flag := range f.sorted() {
+		printflag(w, indent, opts, flag)
+	}
+	f.printpositionals(w, indent)
+	f.printconstraints(w, indent, opts)
+}
+
+// printflag prints flag and its sub flags, if any, to w indented with
+// indent using opts.
+func printflag(w io.Writer, indent string, opts parseopts, flag *Flag) {
+	syntax := opts.syntax
+	help := flag.help
+	if flag.haschoices() && !flag.choicehelp() {
+		help = fmt.Sprintf("%s [choices: %s]", help, flag.choicelist(", "))
+	}
+	if env := envname(flag, opts.envprefix); env != "" {
+		help = fmt.Sprintf("%s [env: %s]", help, env)
+	}
+	val := flag.key
+	if flag.negatable {
+		val = "[no-]" + val
+	}
+	long := syntax.Long
+	if syntax.Negate != "" && flag.kind == KindSwitch {
+		long = fmt.Sprintf("[%s|%s]", syntax.Long, syntax.Negate)
+	}
+	paramhelp := flag.paramhelp
+	if paramhelp == "" && flag.haschoices() {
+		paramhelp = flag.choicelist("|")
+	}
+	if paramhelp != "" {
+		if flag.kind == KindOptionalParam {
+			val = fmt.Sprintf("%s[%s<%s>]", val, syntax.separator(), paramhelp)
 		} else {
-			fmt.Fprintf(w, "%s-%s\t--%s\t%s\t\n", indent, flag.Shortkey(), val, flag.Help())
+			val = fmt.Sprintf("%s <%s>", val, paramhelp)
 		}
-		if flag.sub != nil {
-			flag.sub.printindent(w, indent+"\t")
+		if flag.repeatable {
+			val += "..."
 		}
 	}
+	if flag.shortkey == "" {
+		fmt.Fprintf(w, "%s%s\t%s%s\t%s\t\n", indent, "", long, val, help)
+	} else {
+		fmt.Fprintf(w, "%s%s%s\t%s%s\t%s\t\n", indent, syntax.Short, flag.shortkey, long, val, help)
+	}
+	if flag.choicehelp() {
+		flag.printchoices(w, indent)
+	}
+	if flag.sub != nil {
+		flag.sub.mu.RLock()
+		flag.sub.printindent(w, indent+"\t", opts.subopts(flag))
+		flag.sub.mu.RUnlock()
+	}
 }
 
 // String returns a printable string of Flags.
+// If Flags define positionals the string is prefixed with a usage synopsis.
 func (f *Flags) String() string {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
 	buf := bytes.NewBuffer(nil)
+	if len(f.pos) > 0 {
+		fmt.Fprintf(buf, "Usage: %s\n\n", f.synopsis())
+	}
 	w := tabwriter.NewWriter(buf, 0, 0, 3, ' ', 0)
-	f.printindent(w, "")
+	f.printindent(w, "", parseopts{syntax: DefaultSyntax})
+	w.Flush()
+	return string(buf.Bytes())
+}
+
+// OperationString returns a printable string of operation under specified
+// key prefixed with a usage synopsis as in "Usage: {-S --sync} [options]".
+// Operation options are followed by global options. If no operation is
+// defined under key an empty string is returned.
+func (f *Flags) OperationString(key string) string {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	op, ok := f.getkey(key)
+	if !ok || op.kind != KindOperation {
+		return ""
+	}
+	opts := f.opts(parseopts{syntax: DefaultSyntax})
+	usage := []string{opts.syntax.Long + op.key, "[options]"}
+	if op.shortkey != "" {
+		usage[0] = fmt.Sprintf("{%s%s %s}", opts.syntax.Short, op.shortkey, usage[0])
+	}
+	op.sub.mu.RLock()
+	defer op.sub.mu.RUnlock()
+	for _, p := range op.sub.pos {
+		usage = append(usage, p.synopsis())
+	}
+	buf := bytes.NewBuffer(nil)
+	fmt.Fprintf(buf, "Usage: %s\n\n", strings.Join(usage, " "))
+	w := tabwriter.NewWriter(buf, 0, 0, 3, ' ', 0)
+	op.sub.printindent(w, "", opts.subopts(op))
+	for _, flag := range f.sorted() {
+		if flag.kind != KindOperation {
+			printflag(w, "", opts, flag)
+		}
+	}
 	w.Flush()
 	return string(buf.Bytes())
 }
 
 // ParseMap returns a map of parsed Flag key:value pairs.
 // Sub will return a map, Flags may return a string if parsed or
-// nil if not parsed. ParseMap returns whichever args were parsed
-// at last Parse. ParseMap is as valid as what Parse returned.
+// nil if not parsed. Repeatable Flags and Flags with a separator
+// return a slice of strings and counters return an int.
+// ParseMap returns whichever args were parsed at last Parse.
+// ParseMap is as valid as what Parse returned.
 func (f *Flags) ParseMap() map[interface{}]interface{} {
-	ret := make(map[interface{}]interface{})
-	for kk, kv := range f.keys {
-		if kv.Parsed() {
-			if kv.sub != nil {
-				_, ok := ret[kk]
-				if !ok {
-					ret[kk] = make(map[interface{}]interface{})
-				}
-				ret[kk] = kv.sub.ParseMap()
-				continue
-			}
-			if kv.ParsedVal() {
-				ret[kk] = kv.Value()
-			} else {
-				ret[kk] = nil
-			}
-		}
-	}
-	return ret
+	f.mu.RLock()
+	r := f.result
+	f.mu.RUnlock()
+	return r.ParseMap()
 }
 
 // Parsed returns if flags were parsed if no keys are specified.
 // If one or more keys are specified, returns if all of the specified
 // keys were specified and parsed.
 func (f *Flags) Parsed(keys ...string) bool {
-	if len(keys) > 0 {
-		for _, key := range keys {
-			if flag, ok := f.GetKey(key); ok {
-				if !flag.Parsed() {
-					return false
-				}
-				continue
-			}
-			return false
-		}
-	}
-	return f.parsed
+	f.mu.RLock()
+	r := f.result
+	f.mu.RUnlock()
+	return r.Parsed(keys...)
 }
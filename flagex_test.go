@@ -10,6 +10,7 @@ import (
 	"log"
 	"os"
 	"strings"
+	"sync"
 	"testing"
 )
 
@@ -327,8 +328,8 @@ func TestMux(t *testing.T) {
 
 	var TestItems = []TestItem{
 		{"", ErrNoArgs},
-		{"-", ErrNotFound},
-		{"--", ErrNotFound},
+		{"-", nil},
+		{"--", ErrNoArgs},
 		{"-P", ErrSub},
 		{"-P -l", nil},
 		{"-P -e", nil},
@@ -346,10 +347,10 @@ func TestMux(t *testing.T) {
 		{"-S -t target -i -u", ErrExclusive},
 		{"-S -t target -i -b", ErrExclusive},
 		{"-S -t target -u -b", ErrExclusive},
-		{"-S -t target -i -v extra", ErrSwitch},
+		{"-S -t target -i -v extra", ErrRequired},
 		{"-S -i -v -t", ErrReqVal},
 		{"-S -? -v", ErrNotFound},
-		{"-S -v -?", ErrSwitch},
+		{"-S -v -?", ErrNotFound},
 		{"-S -? -!", ErrNotFound},
 		{"-S", ErrSub},
 		{"-?", ErrNotFound},
@@ -368,7 +369,7 @@ func TestMux(t *testing.T) {
 		{"-S --target target -m mode -v", nil},
 		{"-S -t target --mode mode -v", nil},
 		{"-S --target target --mode mode -v", nil},
-		{"-S --target target --mode mode -v extra", ErrSwitch},
+		{"-S --target target --mode mode -v extra", nil},
 		{"-S --target target --mode mode -v -v", ErrDuplicate},
 		{"-S --target target --mode mode -v --target target", ErrDuplicate},
 		{"-S --target target --mode mode -v --mode mode", ErrDuplicate},
@@ -463,6 +464,940 @@ func TestValue(t *testing.T) {
 	}
 }
 
+func TestArgs(t *testing.T) {
+	sub := New()
+	sub.DefineSwitch("force", "f", "force")
+	f := New()
+	f.DefineSwitch("verbose", "v", "verbose")
+	f.DefineOptional("output", "o", "output", "filename", "")
+	f.DefineSub("copy", "c", "copy", sub)
+
+	type Test struct {
+		Args     string
+		Root     []string
+		Sub      []string
+		Output   string
+		Expected error
+	}
+
+	tests := []Test{
+		{"in.txt", []string{"in.txt"}, nil, "", nil},
+		{"--verbose in.txt out.txt", []string{"in.txt", "out.txt"}, nil, "", nil},
+		{"in.txt -v out.txt", []string{"in.txt", "out.txt"}, nil, "", nil},
+		{"-o out.txt in.txt", []string{"in.txt"}, nil, "out.txt", nil},
+		{"-v -- -weird-file", []string{"-weird-file"}, nil, "", nil},
+		{"-- -v --", []string{"-v", "--"}, nil, "", nil},
+		{"-o -- in.txt", []string{"in.txt"}, nil, "", nil},
+		{"a -c b -f c", []string{"a"}, []string{"b", "c"}, "", nil},
+		{"-cf a -- -b", nil, []string{"a", "-b"}, "", nil},
+		{"-c --", nil, nil, "", ErrNoArgs},
+		{"-v -x", nil, nil, "", ErrNotFound},
+		{"-v - out.txt", []string{"-", "out.txt"}, nil, "", nil},
+		{"-o - -", []string{"-"}, nil, "-", nil},
+		{"-c - -f", nil, []string{"-"}, "", nil},
+	}
+
+	for _, test := range tests {
+		err := f.Parse(strings.Split(test.Args, " "))
+		if !errors.Is(err, test.Expected) {
+			t.Fatalf("'%s': expected '%v', got '%v'", test.Args, test.Expected, err)
+		}
+		if err != nil {
+			continue
+		}
+		if fmt.Sprint(f.Args()) != fmt.Sprint(test.Root) {
+			t.Fatalf("'%s': expected root args '%v', got '%v'", test.Args, test.Root, f.Args())
+		}
+		if fmt.Sprint(sub.Args()) != fmt.Sprint(test.Sub) {
+			t.Fatalf("'%s': expected sub args '%v', got '%v'", test.Args, test.Sub, sub.Args())
+		}
+		if v := f.GetValue("output"); v != test.Output {
+			t.Fatalf("'%s': expected output '%s', got '%s'", test.Args, test.Output, v)
+		}
+	}
+}
+
+func TestInline(t *testing.T) {
+	sub := New()
+	sub.DefineSwitch("all", "a", "all")
+	sub.DefineOptional("target", "t", "target", "target", "")
+	f := New()
+	f.DefineSwitch("verbose", "v", "verbose")
+	f.DefineSwitch("quiet", "q", "quiet")
+	f.DefineOptional("config", "c", "config", "filename", "default.json")
+	f.DefineRequired("mode", "m", "mode", "mode", "")
+	f.DefineSub("sync", "S", "sync", sub)
+
+	type Test struct {
+		Args     string
+		Config   string
+		Target   string
+		Expected error
+	}
+
+	tests := []Test{
+		{"-m x --config=settings.json", "settings.json", "", nil},
+		{"-m x --config=a=b", "a=b", "", nil},
+		{"-m x --config=", "default.json", "", nil},
+		{"-m x -c=file.json", "file.json", "", nil},
+		{"-m x -cfile.json", "file.json", "", nil},
+		{"-m x -vc=file.json", "file.json", "", nil},
+		{"-m x -vqc=file.json", "file.json", "", nil},
+		{"-m x -vc file.json", "file.json", "", nil},
+		{"-m x -vcfile.json", "file.json", "", nil},
+		{"-mx -vc", "default.json", "", nil},
+		{"-m=x", "default.json", "", nil},
+		{"--mode=x -S -t=a", "default.json", "a", nil},
+		{"--mode=x -St=a", "default.json", "a", nil},
+		{"--mode=x -Sat=a", "default.json", "a", nil},
+		{"--mode=x -Stfoo", "default.json", "foo", nil},
+		{"--mode=x -Sa --target=foo", "default.json", "foo", nil},
+		{"--mode= -v", "", "", ErrReqVal},
+		{"-m x -v=1", "default.json", "", nil},
+		{"-m x -v=x", "", "", ErrSwitch},
+		{"-m x --verbose=yes", "", "", ErrSwitch},
+		{"-m x --sync=1", "", "", ErrSwitch},
+		{"-m x --conf=a", "", "", ErrNotFound},
+		{"-m x -xc=a", "", "", ErrNotFound},
+		{"-m x -vSa", "", "", ErrNotSub},
+		{"-m x -vmz", "", "", ErrDuplicate},
+	}
+
+	for _, test := range tests {
+		err := f.Parse(strings.Split(test.Args, " "))
+		if !errors.Is(err, test.Expected) {
+			t.Fatalf("'%s': expected '%v', got '%v'", test.Args, test.Expected, err)
+		}
+		if err != nil {
+			continue
+		}
+		if v := f.GetValue("config"); v != test.Config {
+			t.Fatalf("'%s': expected config '%s', got '%s'", test.Args, test.Config, v)
+		}
+		if v := sub.GetValue("target"); v != test.Target {
+			t.Fatalf("'%s': expected target '%s', got '%s'", test.Args, test.Target, v)
+		}
+	}
+}
+
+func TestRepeatable(t *testing.T) {
+	f := New()
+	f.DefineOptional("include", "I", "include dir", "dir", "")
+	f.DefineOptional("tags", "t", "tags", "tag", "a,b")
+	f.DefineOptional("output", "o", "output", "filename", "")
+	f.SetExclusive("include", "output")
+	include, _ := f.GetKey("include")
+	include.SetRepeatable(true)
+	tags, _ := f.GetKey("tags")
+	tags.SetSeparator(",")
+
+	type Test struct {
+		Args     string
+		Include  []string
+		Tags     []string
+		Expected error
+	}
+
+	tests := []Test{
+		{"--include a --include b", []string{"a", "b"}, []string{"a", "b"}, nil},
+		{"-I a -Ib --include=c", []string{"a", "b", "c"}, []string{"a", "b"}, nil},
+		{"-I a --tags x,y", []string{"a"}, []string{"x", "y"}, nil},
+		{"-t x -t y", nil, nil, ErrDuplicate},
+		{"-I a -o b", nil, nil, ErrExclusive},
+	}
+
+	for _, test := range tests {
+		err := f.Parse(strings.Split(test.Args, " "))
+		if !errors.Is(err, test.Expected) {
+			t.Fatalf("'%s': expected '%v', got '%v'", test.Args, test.Expected, err)
+		}
+		if err != nil {
+			continue
+		}
+		if fmt.Sprint(include.Values()) != fmt.Sprint(test.Include) {
+			t.Fatalf("'%s': expected include '%v', got '%v'", test.Args, test.Include, include.Values())
+		}
+		if fmt.Sprint(tags.Values()) != fmt.Sprint(test.Tags) {
+			t.Fatalf("'%s': expected tags '%v', got '%v'", test.Args, test.Tags, tags.Values())
+		}
+	}
+
+	f.Parse([]string{"-I", "a", "-I", "b"})
+	v, ok := f.ParseMap()["include"].([]string)
+	if !ok || fmt.Sprint(v) != "[a b]" {
+		t.Fatalf("ParseMap failed: %#v", f.ParseMap())
+	}
+	if include.Value() != "b" {
+		t.Fatal("Value() failed")
+	}
+}
+
+func TestCounter(t *testing.T) {
+	sub := New()
+	sub.DefineCounter("refresh", "y", "refresh databases")
+	sub.DefineSwitch("upgrade", "u", "upgrade packages")
+	f := New()
+	f.DefineCounter("verbose", "v", "verbose output")
+	f.DefineOptional("config", "c", "config", "filename", "")
+	f.DefineSub("sync", "S", "sync", sub)
+	verbose, _ := f.GetKey("verbose")
+	refresh, _ := sub.GetKey("refresh")
+
+	type Test struct {
+		Args     string
+		Verbose  int
+		Refresh  int
+		Expected error
+	}
+
+	tests := []Test{
+		{"-v", 1, 0, nil},
+		{"-vvv", 3, 0, nil},
+		{"--verbose --verbose", 2, 0, nil},
+		{"-vv --verbose -vc file", 4, 0, nil},
+		{"-Syy", 0, 2, nil},
+		{"-Syyu", 0, 2, nil},
+		{"-vv -Syuy", 2, 2, nil},
+		{"-v -S -yy --refresh", 1, 3, nil},
+		{"-v=2", 0, 0, ErrSwitch},
+		{"-Syuu", 0, 0, ErrDuplicate},
+	}
+
+	for _, test := range tests {
+		err := f.Parse(strings.Split(test.Args, " "))
+		if !errors.Is(err, test.Expected) {
+			t.Fatalf("'%s': expected '%v', got '%v'", test.Args, test.Expected, err)
+		}
+		if err != nil {
+			continue
+		}
+		if verbose.Count() != test.Verbose {
+			t.Fatalf("'%s': expected verbose '%d', got '%d'", test.Args, test.Verbose, verbose.Count())
+		}
+		if refresh.Count() != test.Refresh {
+			t.Fatalf("'%s': expected refresh '%d', got '%d'", test.Args, test.Refresh, refresh.Count())
+		}
+	}
+
+	f.Parse([]string{"-vv", "-Syy"})
+	m := f.ParseMap()
+	if m["verbose"] != 2 || m["sync"].(map[interface{}]interface{})["refresh"] != 2 {
+		t.Fatalf("ParseMap failed: %#v", m)
+	}
+	if verbose.Value() != "2" {
+		t.Fatal("Value() failed")
+	}
+}
+
+func TestNegatable(t *testing.T) {
+	f := New()
+	f.DefineSwitch("color", "c", "colored output")
+	f.DefineSwitch("verbose", "v", "verbose output")
+	f.DefineCounter("debug", "d", "debug level")
+	f.DefineOptional("config", "", "config file", "file", "")
+	color, _ := f.GetKey("color")
+	if err := color.SetNegatable(true); err != nil {
+		t.Fatal(err)
+	}
+	verbose, _ := f.GetKey("verbose")
+	for _, key := range []string{"debug", "config"} {
+		flag, _ := f.GetKey(key)
+		if err := flag.SetNegatable(true); !errors.Is(err, ErrNegate) {
+			t.Fatalf("'%s': expected ErrNegate, got '%v'", key, err)
+		}
+	}
+
+	type Test struct {
+		Args     string
+		Parsed   bool
+		Color    bool
+		Verbose  bool
+		Expected error
+	}
+
+	tests := []Test{
+		{"-v", false, false, true, nil},
+		{"--color", true, true, false, nil},
+		{"--no-color", true, false, false, nil},
+		{"--color --no-color", true, false, false, nil},
+		{"--no-color -c", true, true, false, nil},
+		{"--color=false -v=true", true, false, true, nil},
+		{"-c=0 --verbose=f", true, false, false, nil},
+		{"--color=true", true, true, false, nil},
+		{"--no-verbose", false, false, false, ErrNotFound},
+		{"--no-debug", false, false, false, ErrNotFound},
+		{"--no-config", false, false, false, ErrNotFound},
+		{"--no-color=true", false, false, false, ErrSwitch},
+		{"--color=maybe", false, false, false, ErrSwitch},
+		{"--debug=true", false, false, false, ErrSwitch},
+		{"-v -v=false", false, false, false, ErrDuplicate},
+	}
+
+	for _, test := range tests {
+		err := f.Parse(strings.Split(test.Args, " "))
+		if !errors.Is(err, test.Expected) {
+			t.Fatalf("'%s': expected '%v', got '%v'", test.Args, test.Expected, err)
+		}
+		if err != nil {
+			continue
+		}
+		if color.Parsed() != test.Parsed {
+			t.Fatalf("'%s': expected color parsed '%t', got '%t'", test.Args, test.Parsed, color.Parsed())
+		}
+		if color.Bool() != test.Color {
+			t.Fatalf("'%s': expected color '%t', got '%t'", test.Args, test.Color, color.Bool())
+		}
+		if verbose.Bool() != test.Verbose {
+			t.Fatalf("'%s': expected verbose '%t', got '%t'", test.Args, test.Verbose, verbose.Bool())
+		}
+	}
+
+	if s := f.String(); !strings.Contains(s, "--[no-]color") {
+		t.Fatal(s)
+	}
+}
+
+func TestOptionalParam(t *testing.T) {
+	f := New()
+	f.DefineOptionalParam("color", "c", "colored output", "when", "auto")
+	f.DefineSwitch("verbose", "v", "verbose output")
+
+	type Test struct {
+		Args      string
+		ParsedVal bool
+		Color     string
+		Operands  []string
+		Expected  error
+	}
+
+	tests := []Test{
+		{"--color --verbose", false, "auto", nil, nil},
+		{"--color=never", true, "never", nil, nil},
+		{"--color never", false, "auto", []string{"never"}, nil},
+		{"-c never", false, "auto", []string{"never"}, nil},
+		{"-c=always", true, "always", nil, nil},
+		{"-calways", true, "always", nil, nil},
+		{"-vc=always", true, "always", nil, nil},
+		{"-cv", false, "auto", nil, nil},
+		{"-v", false, "auto", nil, nil},
+		{"--color --color", false, "", nil, ErrDuplicate},
+	}
+
+	color, _ := f.GetKey("color")
+	for _, test := range tests {
+		err := f.Parse(strings.Split(test.Args, " "))
+		if !errors.Is(err, test.Expected) {
+			t.Fatalf("'%s': expected '%v', got '%v'", test.Args, test.Expected, err)
+		}
+		if err != nil {
+			continue
+		}
+		if color.ParsedVal() != test.ParsedVal {
+			t.Fatalf("'%s': expected parsedval '%t', got '%t'", test.Args, test.ParsedVal, color.ParsedVal())
+		}
+		if color.Value() != test.Color {
+			t.Fatalf("'%s': expected color '%s', got '%s'", test.Args, test.Color, color.Value())
+		}
+		if fmt.Sprint(f.Args()) != fmt.Sprint(test.Operands) {
+			t.Fatalf("'%s': expected args '%v', got '%v'", test.Args, test.Operands, f.Args())
+		}
+	}
+
+	if s := f.String(); !strings.Contains(s, "--color[=<when>]") {
+		t.Fatal(s)
+	}
+}
+
+func TestUnknownPolicy(t *testing.T) {
+	sub := New()
+	sub.DefineSwitch("force", "f", "force")
+	f := New()
+	f.DefineSwitch("verbose", "v", "verbose")
+	f.DefineOptional("host", "h", "host", "hostname", "")
+	f.DefineSub("exec", "e", "exec", sub)
+
+	type Test struct {
+		Args     string
+		Policy   UnknownPolicy
+		Root     []string
+		Sub      []string
+		Operands []string
+		Expected error
+	}
+
+	tests := []Test{
+		{"-v --port 22 -x", UnknownError, nil, nil, nil, ErrNotFound},
+		{"-v -vx", UnknownError, nil, nil, nil, ErrNotFound},
+		{"-v --port 22 -x -h host", UnknownIgnore, nil, nil, nil, nil},
+		{"-o x", UnknownIgnore, nil, nil, nil, nil},
+		{"-o x", UnknownPass, []string{"-o", "x"}, nil, nil, nil},
+		{"-v --port 22 -x -h host", UnknownPass, []string{"--port", "22", "-x"}, nil, nil, nil},
+		{"--port=22 file -o opt", UnknownPass, []string{"--port=22", "-o", "opt"}, nil, []string{"file"}, nil},
+		{"--port -v -A", UnknownPass, []string{"--port", "-A"}, nil, nil, nil},
+		{"-A -- -B", UnknownPass, []string{"-A"}, nil, []string{"-B"}, nil},
+		{"-A -e -f -B b", UnknownPass, []string{"-A"}, []string{"-B", "b"}, nil, nil},
+	}
+
+	for _, test := range tests {
+		f.SetUnknownPolicy(test.Policy)
+		sub.SetUnknownPolicy(test.Policy)
+		err := f.Parse(strings.Split(test.Args, " "))
+		if !errors.Is(err, test.Expected) {
+			t.Fatalf("'%s': expected '%v', got '%v'", test.Args, test.Expected, err)
+		}
+		if err != nil {
+			continue
+		}
+		if fmt.Sprint(f.Unknown()) != fmt.Sprint(test.Root) {
+			t.Fatalf("'%s': expected root unknown '%v', got '%v'", test.Args, test.Root, f.Unknown())
+		}
+		if fmt.Sprint(sub.Unknown()) != fmt.Sprint(test.Sub) {
+			t.Fatalf("'%s': expected sub unknown '%v', got '%v'", test.Args, test.Sub, sub.Unknown())
+		}
+		if fmt.Sprint(f.Args()) != fmt.Sprint(test.Operands) {
+			t.Fatalf("'%s': expected args '%v', got '%v'", test.Args, test.Operands, f.Args())
+		}
+	}
+}
+
+func TestParseMode(t *testing.T) {
+	sub := New()
+	sub.DefineSwitch("all", "a", "all")
+	sub.DefineSwitch("force", "f", "force")
+	f := New()
+	f.DefineSwitch("all", "a", "all")
+	f.DefineSwitch("bare", "b", "bare")
+	f.DefineOptional("config", "c", "config", "filename", "")
+	f.DefineSub("exec", "e", "exec", sub)
+
+	type Test struct {
+		Args     string
+		Mode     ParseMode
+		SubMode  ParseMode
+		Root     []string
+		Sub      []string
+		Bare     bool
+		Expected error
+	}
+
+	tests := []Test{
+		{"-a file -b", ModeDefault, ModeDefault, []string{"file"}, nil, true, nil},
+		{"-a file -b", ModeInterspersed, ModeDefault, []string{"file"}, nil, true, nil},
+		{"-a file -b", ModePOSIX, ModeDefault, []string{"file", "-b"}, nil, false, nil},
+		{"-a file -bc x", ModeInterspersed, ModeDefault, []string{"file"}, nil, true, nil},
+		{"-ac x file -ab", ModePOSIX, ModeDefault, []string{"file", "-ab"}, nil, false, nil},
+		{"-acx file -- -b", ModePOSIX, ModeDefault, []string{"file", "--", "-b"}, nil, false, nil},
+		{"-b -ea file -f", ModePOSIX, ModeDefault, nil, []string{"file", "-f"}, true, nil},
+		{"-b -ea file -f", ModePOSIX, ModeInterspersed, nil, []string{"file"}, true, nil},
+		{"-b -ea file -f", ModeInterspersed, ModePOSIX, nil, []string{"file", "-f"}, true, nil},
+		{"-b -e file -f", ModeDefault, ModeDefault, nil, []string{"file"}, true, nil},
+		{"-a file -x", ModeInterspersed, ModeDefault, nil, nil, false, ErrNotFound},
+		{"-a file -x", ModePOSIX, ModeDefault, []string{"file", "-x"}, nil, false, nil},
+	}
+
+	bare, _ := f.GetKey("bare")
+	for _, test := range tests {
+		f.SetParseMode(test.Mode)
+		sub.SetParseMode(test.SubMode)
+		err := f.Parse(strings.Split(test.Args, " "))
+		if !errors.Is(err, test.Expected) {
+			t.Fatalf("'%s': expected '%v', got '%v'", test.Args, test.Expected, err)
+		}
+		if err != nil {
+			continue
+		}
+		if fmt.Sprint(f.Args()) != fmt.Sprint(test.Root) {
+			t.Fatalf("'%s': expected root args '%v', got '%v'", test.Args, test.Root, f.Args())
+		}
+		if fmt.Sprint(sub.Args()) != fmt.Sprint(test.Sub) {
+			t.Fatalf("'%s': expected sub args '%v', got '%v'", test.Args, test.Sub, sub.Args())
+		}
+		if bare.Parsed() != test.Bare {
+			t.Fatalf("'%s': expected bare '%t', got '%t'", test.Args, test.Bare, bare.Parsed())
+		}
+	}
+
+	for _, mode := range []ParseMode{ModeInterspersed, ModePOSIX} {
+		f.SetParseMode(mode)
+		for _, args := range [][]string{{"-a", " x ", " y ", "", "z"}, {"-a", "--", " x ", " y ", "", "z"}} {
+			if err := f.Parse(args); err != nil {
+				t.Fatal(err)
+			}
+			if s := strings.Join(f.Args(), "|"); s != "x|y|z" {
+				t.Fatalf("%s: expected trimmed operands, got '%s'", mode, s)
+			}
+		}
+	}
+
+	f.SetParseMode(ModeEnv)
+	sub.SetParseMode(ModeDefault)
+	os.Setenv("POSIXLY_CORRECT", "1")
+	f.Parse([]string{"file", "-b"})
+	if bare.Parsed() {
+		t.Fatal("POSIXLY_CORRECT not honoured")
+	}
+	os.Unsetenv("POSIXLY_CORRECT")
+	f.Parse([]string{"file", "-b"})
+	if !bare.Parsed() {
+		t.Fatal("POSIXLY_CORRECT honoured when not set")
+	}
+}
+
+func TestAbbreviations(t *testing.T) {
+	sub := New()
+	sub.DefineSwitch("force", "f", "force")
+	f := New()
+	f.DefineSwitch("verbose", "v", "verbose")
+	f.DefineSwitch("version", "V", "version")
+	f.DefineSwitch("color", "", "color")
+	f.DefineOptional("config", "c", "config", "filename", "")
+	f.DefineSub("sync", "S", "sync", sub)
+	f.DefineSwitch("silent", "s", "silent")
+	color, _ := f.GetKey("color")
+	color.SetNegatable(true)
+
+	type Test struct {
+		Args     string
+		Abbrev   bool
+		Parsed   []string
+		Expected error
+	}
+
+	tests := []Test{
+		{"--verb", false, nil, ErrNotFound},
+		{"--verb", true, []string{"verbose"}, nil},
+		{"--vers", true, []string{"version"}, nil},
+		{"--verbose", true, []string{"verbose"}, nil},
+		{"--ver", true, nil, ErrAmbiguous},
+		{"--co", true, nil, ErrAmbiguous},
+		{"--conf=a.json --col", true, []string{"config", "color"}, nil},
+		{"--conf a.json", true, []string{"config"}, nil},
+		{"--no-c", true, []string{"color"}, nil},
+		{"--n", true, []string{"color"}, nil},
+		{"--s", true, nil, ErrAmbiguous},
+		{"--sy -f", true, []string{"sync"}, nil},
+		{"--sil", true, []string{"silent"}, nil},
+		{"--x", true, nil, ErrNotFound},
+	}
+
+	for _, test := range tests {
+		f.SetAbbreviations(test.Abbrev)
+		err := f.Parse(strings.Split(test.Args, " "))
+		if !errors.Is(err, test.Expected) {
+			t.Fatalf("'%s': expected '%v', got '%v'", test.Args, test.Expected, err)
+		}
+		if err != nil {
+			continue
+		}
+		if !f.Parsed(test.Parsed...) {
+			t.Fatalf("'%s': expected '%v' parsed, got '%v'", test.Args, test.Parsed, f.ParseMap())
+		}
+	}
+
+	f.SetAbbreviations(true)
+	err := f.Parse([]string{"--ver"})
+	if err == nil || !strings.Contains(err.Error(), "--verbose, --version") {
+		t.Fatalf("candidates not listed: %v", err)
+	}
+}
+
+func TestNormalization(t *testing.T) {
+	f := New()
+	if err := f.SetNormalization(NormFoldKeys | NormDashes | NormFoldShort); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.DefineSwitch("dry-run", "n", "dry run"); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.DefineSwitch("Dry_Run", "", "dry run"); !errors.Is(err, ErrDuplicate) {
+		t.Fatal(err)
+	}
+	if err := f.DefineSwitch("verbose", "N", "verbose"); !errors.Is(err, ErrDupShort) {
+		t.Fatal(err)
+	}
+	if err := f.DefineSwitch("color", "c", "color"); err != nil {
+		t.Fatal(err)
+	}
+	color, _ := f.GetKey("COLOR")
+	color.SetNegatable(true)
+
+	for _, args := range []string{"--dry-run", "--dry_run", "--Dry_Run", "--DRY-RUN", "-n", "-N"} {
+		if err := f.Parse([]string{args}); err != nil {
+			t.Fatalf("'%s': %v", args, err)
+		}
+		if !f.Parsed("dry-run") || !f.Parsed("DRY_RUN") {
+			t.Fatalf("'%s': not parsed", args)
+		}
+		if _, ok := f.ParseMap()["dry-run"]; !ok {
+			t.Fatalf("'%s': ParseMap failed: %v", args, f.ParseMap())
+		}
+	}
+	if err := f.Parse([]string{"--No_Color"}); err != nil || color.Bool() {
+		t.Fatal(err)
+	}
+	f.SetAbbreviations(true)
+	if err := f.Parse([]string{"--DRY"}); err != nil {
+		t.Fatal(err)
+	}
+
+	g := New()
+	g.DefineSwitch("Verbose", "v", "verbose")
+	g.DefineSwitch("verbose", "V", "verbose")
+	if err := g.Parse([]string{"--verbose", "--Verbose"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.SetNormalization(NormFoldKeys); !errors.Is(err, ErrDuplicate) {
+		t.Fatal(err)
+	}
+	if err := g.SetNormalization(NormFoldShort); !errors.Is(err, ErrDupShort) {
+		t.Fatal(err)
+	}
+	if g.Normalization() != 0 {
+		t.Fatal("normalization changed")
+	}
+	if err := g.Parse([]string{"-v", "-V"}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSyntax(t *testing.T) {
+	sub := New()
+	sub.DefineSwitch("force", "f", "force")
+	sub.DefineOptional("target", "t", "target", "target", "")
+	f := New()
+	f.DefineSwitch("verbose", "v", "verbose")
+	f.DefineSwitch("errexit", "e", "exit on error")
+	f.DefineOptional("config", "c", "config", "filename", "")
+	f.DefineOptionalParam("color", "", "color", "when", "auto")
+	f.DefineSub("sync", "S", "sync", sub)
+
+	type Test struct {
+		Args     string
+		Syntax   Syntax
+		Parsed   []string
+		Config   string
+		Target   string
+		Operands []string
+		Expected error
+	}
+
+	tests := []Test{
+		{"/verbose /c:file.json", WindowsSyntax, []string{"verbose", "config"}, "file.json", "", nil, nil},
+		{"/v /config=file.json a", WindowsSyntax, []string{"verbose", "config"}, "file.json", "", []string{"a"}, nil},
+		{"/vc:file.json", WindowsSyntax, []string{"verbose", "config"}, "file.json", "", nil, nil},
+		{"/c file.json -- -v", WindowsSyntax, []string{"config"}, "file.json", "", []string{"--", "-v"}, nil},
+		{"/color:never", WindowsSyntax, []string{"color"}, "", "", nil, nil},
+		{"/Sf /t:x", WindowsSyntax, []string{"sync"}, "", "x", nil, nil},
+		{"/sync /force /target x", WindowsSyntax, []string{"sync"}, "", "x", nil, nil},
+		{"/x", WindowsSyntax, nil, "", "", nil, ErrNotFound},
+		{"+e +verbose", PlusMinusSyntax, []string{"errexit", "verbose"}, "", "", nil, nil},
+		{"-e", PlusMinusSyntax, []string{"errexit"}, "", "", nil, nil},
+		{"-ev +c file", PlusMinusSyntax, []string{"errexit", "verbose", "config"}, "file", "", nil, nil},
+		{"-verbose", PlusMinusSyntax, []string{"verbose"}, "", "", nil, nil},
+		{"+S +f -- +v", PlusMinusSyntax, []string{"sync"}, "", "", nil, nil},
+		{"+e -e", PlusMinusSyntax, []string{"errexit"}, "", "", nil, nil},
+		{"-e +e -ve", PlusMinusSyntax, []string{"errexit", "verbose"}, "", "", nil, nil},
+		{"-config", PlusMinusSyntax, nil, "", "", nil, ErrNegate},
+		{"-ec", PlusMinusSyntax, nil, "", "", nil, ErrNegate},
+		{"--verbose", PlusMinusSyntax, nil, "", "", nil, ErrNotFound},
+		{"--verbose", Syntax{Long: "--", Short: "-"}, []string{"verbose"}, "", "", nil, nil},
+		{"--config=x", Syntax{Long: "--", Short: "-"}, nil, "", "", nil, ErrNotFound},
+	}
+
+	for _, test := range tests {
+		if err := f.SetSyntax(test.Syntax); err != nil {
+			t.Fatal(err)
+		}
+		err := f.Parse(strings.Split(test.Args, " "))
+		if !errors.Is(err, test.Expected) {
+			t.Fatalf("'%s': expected '%v', got '%v'", test.Args, test.Expected, err)
+		}
+		if err != nil {
+			continue
+		}
+		if !f.Parsed(test.Parsed...) {
+			t.Fatalf("'%s': expected '%v' parsed, got '%v'", test.Args, test.Parsed, f.ParseMap())
+		}
+		if v := f.GetValue("config"); v != test.Config {
+			t.Fatalf("'%s': expected config '%s', got '%s'", test.Args, test.Config, v)
+		}
+		if v := sub.GetValue("target"); v != test.Target {
+			t.Fatalf("'%s': expected target '%s', got '%s'", test.Args, test.Target, v)
+		}
+		if fmt.Sprint(f.Args()) != fmt.Sprint(test.Operands) {
+			t.Fatalf("'%s': expected args '%v', got '%v'", test.Args, test.Operands, f.Args())
+		}
+	}
+
+	f.SetSyntax(PlusMinusSyntax)
+	f.Parse([]string{"-e", "+v"})
+	if e, _ := f.GetKey("errexit"); e.Bool() {
+		t.Fatal("negate failed")
+	}
+	f.Parse([]string{"+e", "-e"})
+	if e, _ := f.GetKey("errexit"); e.Bool() {
+		t.Fatal("negate override failed")
+	}
+	f.Parse([]string{"-e", "+e"})
+	if e, _ := f.GetKey("errexit"); !e.Bool() {
+		t.Fatal("override negated failed")
+	}
+	f.SetSyntax(DefaultSyntax)
+	if err := f.Parse([]string{"-e", "-e"}); !errors.Is(err, ErrDuplicate) {
+		t.Fatal(err)
+	}
+	if err := f.SetSyntax(Syntax{Short: "-"}); !errors.Is(err, ErrInvalid) {
+		t.Fatal(err)
+	}
+
+	g := New()
+	g.SetSyntax(WindowsSyntax)
+	g.SetAbbreviations(true)
+	g.DefineSwitch("verbose", "", "verbose")
+	g.DefineSwitch("version", "v", "version")
+	if err := g.Parse([]string{"/v"}); err != nil || !g.Parsed("version") || g.Parsed("verbose") {
+		t.Fatalf("shortkey over abbreviation failed: %v %v", err, g.ParseMap())
+	}
+	if err := g.Parse([]string{"/verb"}); err != nil || !g.Parsed("verbose") {
+		t.Fatalf("abbreviation failed: %v %v", err, g.ParseMap())
+	}
+	err := g.Parse([]string{"/ver"})
+	if !errors.Is(err, ErrAmbiguous) || !strings.Contains(err.Error(), "'/ver' is ambiguous, candidates: /verbose, /version") {
+		t.Fatal(err)
+	}
+
+	f.SetSyntax(WindowsSyntax)
+	s := f.String()
+	for _, v := range []string{"/c", "/config <filename>", "/color[:<when>]", "/f", "/force"} {
+		if !strings.Contains(s, v) {
+			t.Fatalf("'%s' not in '%s'", v, s)
+		}
+	}
+	f.SetSyntax(PlusMinusSyntax)
+	if s := f.String(); !strings.Contains(s, "[+|-]verbose") {
+		t.Fatal(s)
+	}
+}
+
+func TestOperations(t *testing.T) {
+	sync := New()
+	sync.DefineCounter("refresh", "y", "download fresh package databases")
+	sync.DefineSwitch("sysupgrade", "u", "upgrade installed packages")
+	sync.DefineSwitch("search", "s", "search remote repositories")
+	sync.DefineSwitch("needed", "", "do not reinstall up to date packages")
+	sync.DefinePositional("package", "packages to install", ArityRest)
+	query := New()
+	query.DefineSwitch("search", "s", "search locally installed packages")
+	query.DefineCounter("info", "i", "view package information")
+	remove := New()
+	remove.DefineSwitch("recursive", "s", "remove unnecessary dependencies")
+	remove.DefineSwitch("nosave", "n", "remove configuration files")
+	f := New()
+	f.DefineSwitch("noconfirm", "", "do not ask for any confirmation")
+	f.DefineSwitch("verbose", "v", "be verbose")
+	f.DefineOptional("dbpath", "b", "set an alternate database location", "path", "")
+	if err := f.DefineOperation("sync", "S", "synchronize packages", sync); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.DefineOperation("query", "Q", "query the package database", query); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.DefineOperation("remove", "R", "remove packages", remove); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.DefineOperation("upgrade", "U", "upgrade packages", nil); !errors.Is(err, ErrInvalid) {
+		t.Fatal(err)
+	}
+	if err := f.DefineSub("files", "F", "query files", nil); !errors.Is(err, ErrInvalid) {
+		t.Fatal(err)
+	}
+	for _, kind := range []FlagKind{KindSub, KindOperation} {
+		if err := f.Define("upgrade", "U", "upgrade packages", "", "", kind); !errors.Is(err, ErrInvalid) {
+			t.Fatal(err)
+		}
+	}
+
+	type Test struct {
+		Args     string
+		Op       string
+		Opts     []string
+		Globals  []string
+		Expected error
+	}
+
+	tests := []Test{
+		{"-Syu", "sync", []string{"refresh", "sysupgrade"}, nil, nil},
+		{"-S", "sync", nil, nil, nil},
+		{"--sync -y --verbose", "sync", []string{"refresh"}, []string{"verbose"}, nil},
+		{"--noconfirm -Syu pkg --needed", "sync", []string{"refresh", "sysupgrade", "needed"}, []string{"noconfirm"}, nil},
+		{"-Ss foo", "sync", []string{"search"}, nil, nil},
+		{"-Qs foo", "query", []string{"search"}, nil, nil},
+		{"-Rs foo", "remove", []string{"recursive"}, nil, nil},
+		{"-vSy", "sync", []string{"refresh"}, []string{"verbose"}, nil},
+		{"-Syv", "sync", []string{"refresh"}, []string{"verbose"}, nil},
+		{"-Sb /tmp", "sync", nil, []string{"dbpath"}, nil},
+		{"-Sy --dbpath /tmp", "sync", []string{"refresh"}, []string{"dbpath"}, nil},
+		{"-S -Q", "", nil, nil, ErrOperation},
+		{"-SQ", "", nil, nil, ErrOperation},
+		{"-v", "", nil, nil, ErrNoOperation},
+		{"", "", nil, nil, ErrNoArgs},
+		{"-Sn", "", nil, nil, ErrNotFound},
+		{"-y -S", "", nil, nil, ErrNotFound},
+	}
+
+	for _, test := range tests {
+		err := f.Parse(strings.Split(test.Args, " "))
+		if !errors.Is(err, test.Expected) {
+			t.Fatalf("'%s': expected '%v', got '%v'", test.Args, test.Expected, err)
+		}
+		if err != nil {
+			continue
+		}
+		op := f.Operation()
+		if op == nil || op.Key() != test.Op {
+			t.Fatalf("'%s': expected operation '%s', got '%v'", test.Args, test.Op, op)
+		}
+		if !op.Sub().Parsed(test.Opts...) || !f.Parsed(test.Globals...) {
+			t.Fatalf("'%s': expected '%v' and '%v' parsed, got '%v'", test.Args, test.Opts, test.Globals, f.ParseMap())
+		}
+	}
+
+	if err := f.Parse([]string{"-Syyu", "--dbpath", "/tmp", "a", "b"}); err != nil {
+		t.Fatal(err)
+	}
+	if refresh, _ := sync.GetKey("refresh"); refresh.Count() != 2 {
+		t.Fatal("counter in operation failed")
+	}
+	if f.GetValue("dbpath") != "/tmp" || strings.Join(sync.Args(), " ") != "a b" {
+		t.Fatal("global option in operation failed")
+	}
+
+	dup := New()
+	dup.DefineSwitch("verbose", "", "verbose")
+	if err := f.DefineOperation("upgrade", "U", "upgrade", dup); !errors.Is(err, ErrDuplicate) {
+		t.Fatal(err)
+	}
+	if err := f.DefineSwitch("needed", "", "needed"); !errors.Is(err, ErrDuplicate) {
+		t.Fatal(err)
+	}
+	if err := f.DefineSwitch("yes", "y", "yes"); !errors.Is(err, ErrDupShort) {
+		t.Fatal(err)
+	}
+
+	s := f.OperationString("sync")
+	for _, v := range []string{"Usage: {-S --sync} [options] [package...]", "--needed", "--noconfirm", "--dbpath <path>"} {
+		if !strings.Contains(s, v) {
+			t.Fatalf("'%s' not in '%s'", v, s)
+		}
+	}
+	if strings.Contains(s, "--query") || strings.Contains(s, "--info") {
+		t.Fatalf("other operations in '%s'", s)
+	}
+	if f.OperationString("verbose") != "" {
+		t.Fatal("OperationString of a non-operation")
+	}
+}
+
+func TestConcurrentDefinition(t *testing.T) {
+	sub := New()
+	sub.DefineOptional("addr", "a", "listen address", "address", ":80")
+	f := New()
+	f.DefineSwitch("verbose", "v", "verbose")
+	f.DefineSub("serve", "s", "serve", sub)
+	verbose, _ := f.GetKey("verbose")
+	ops := New()
+	ops.DefineSwitch("yes", "y", "yes")
+	g := New()
+	g.DefineOperation("sync", "S", "sync", ops)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 256)
+	for i := 0; i < 16; i++ {
+		wg.Add(8)
+		go func(i int) {
+			defer wg.Done()
+			if err := f.DefineOptional(fmt.Sprintf("plugin%d", i), "", "plugin", "value", ""); err != nil {
+				errs <- err
+			}
+			if err := sub.DefineSwitch(fmt.Sprintf("plugin%d", i), "", "plugin"); err != nil {
+				errs <- err
+			}
+			if err := ops.DefineSwitch(fmt.Sprintf("plugin%d", i), "", "plugin"); err != nil {
+				errs <- err
+			}
+		}(i)
+		go func() {
+			defer wg.Done()
+			if _, err := g.Evaluate([]string{"-Sy"}); err != nil {
+				errs <- err
+			}
+			if s := g.OperationString("sync"); !strings.Contains(s, "--yes") {
+				errs <- fmt.Errorf("operation not printed: %s", s)
+			}
+		}()
+		go func(i int) {
+			defer wg.Done()
+			verbose.SetHelp(fmt.Sprintf("verbose %d", i))
+			verbose.SetDefault("false")
+			if err := f.SetExclusive("verbose", "serve"); err != nil {
+				errs <- err
+			}
+		}(i)
+		go func() {
+			defer wg.Done()
+			if s := f.String(); !strings.Contains(s, "--addr") {
+				errs <- fmt.Errorf("sub not printed: %s", s)
+			}
+			_ = verbose.Help()
+		}()
+		go func() {
+			defer wg.Done()
+			if _, ok := f.GetKey("serve"); !ok {
+				errs <- errors.New("GetKey failed")
+			}
+			_ = f.ParseMap()
+			_ = f.Parsed("verbose")
+		}()
+		go func() {
+			defer wg.Done()
+			r, err := f.Evaluate([]string{"-s", "-a", ":8080"})
+			if err != nil {
+				errs <- err
+				return
+			}
+			if v := r.Sub().Value("addr"); v != ":8080" {
+				errs <- fmt.Errorf("expected ':8080', got '%s'", v)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			r, err := f.Evaluate([]string{"-sa", ":9090"})
+			if err != nil {
+				errs <- err
+				return
+			}
+			if v := r.Sub().Value("addr"); v != ":9090" {
+				errs <- fmt.Errorf("expected ':9090', got '%s'", v)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			if err := f.Parse([]string{"-v"}); err != nil {
+				errs <- err
+			}
+			_ = f.GetValue("verbose")
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+	if _, ok := sub.GetKey("plugin15"); !ok {
+		t.Fatal("concurrent define failed")
+	}
+}
+
 var verboseoutput = false
 
 func init() {
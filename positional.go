@@ -0,0 +1,199 @@
+// Copyright 2020 Vedran Vuk. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package flagex
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Arity specifies how many operands a Positional binds.
+type Arity byte
+
+const (
+	// ArityOne marks a positional as required taking exactly one operand.
+	ArityOne Arity = iota
+	// ArityOptional marks a positional as optional taking at most one operand.
+	ArityOptional
+	// ArityMany marks a positional as required taking one or more operands.
+	ArityMany
+	// ArityRest marks a positional as optional taking all remaining operands.
+	ArityRest
+)
+
+// String implements Stringer interface on Arity.
+func (a Arity) String() string {
+	switch a {
+	case ArityOne:
+		return "one"
+	case ArityOptional:
+		return "optional"
+	case ArityMany:
+		return "many"
+	case ArityRest:
+		return "rest"
+	}
+	return ""
+}
+
+// Positional represents a positional parameter defined in Flags.
+type Positional struct {
+	name, help string
+
+	arity Arity
+	flags *Flags
+}
+
+// Name returns Positional name.
+func (p *Positional) Name() string { return p.name }
+
+// Help returns Positional help text.
+func (p *Positional) Help() string { return p.help }
+
+// Arity returns Positional arity.
+func (p *Positional) Arity() Arity { return p.arity }
+
+// Required returns if Positional requires at least one operand.
+func (p *Positional) Required() bool {
+	return p.arity == ArityOne || p.arity == ArityMany
+}
+
+// Variadic returns if Positional can bind more than one operand.
+func (p *Positional) Variadic() bool {
+	return p.arity == ArityMany || p.arity == ArityRest
+}
+
+// Parsed returns if any operands were bound to Positional at last Parse.
+func (p *Positional) Parsed() bool { return len(p.Values()) > 0 }
+
+// Value returns first operand bound to Positional or an empty string.
+func (p *Positional) Value() string {
+	if values := p.Values(); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// Values returns all operands bound to Positional.
+func (p *Positional) Values() []string {
+	p.flags.mu.RLock()
+	defer p.flags.mu.RUnlock()
+	return p.flags.result.pos[p]
+}
+
+// synopsis returns Positional usage synopsis.
+func (p *Positional) synopsis() string {
+	switch p.arity {
+	case ArityOptional:
+		return fmt.Sprintf("[%s]", p.name)
+	case ArityMany:
+		return fmt.Sprintf("<%s>...", p.name)
+	case ArityRest:
+		return fmt.Sprintf("[%s...]", p.name)
+	}
+	return fmt.Sprintf("<%s>", p.name)
+}
+
+// DefinePositional defines a positional parameter under specified name with
+// specified help and arity. Positionals bind operands in order of definition.
+// name must be unique among positionals in Flags. A variadic positional must
+// be the last one defined and a required positional may not follow an
+// optional one. If a non-nil error is returned positional was not defined.
+func (f *Flags) DefinePositional(name, help string, arity Arity) error {
+	if name == "" {
+		return ErrInvalid
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.getpositional(name); ok {
+		return ErrDuplicate.WrapArgs(name)
+	}
+	if n := len(f.pos); n > 0 {
+		last := f.pos[n-1]
+		if last.Variadic() || (!last.Required() && arity != ArityRest && arity != ArityOptional) {
+			return ErrPosOrder.WrapArgs(name, last.name)
+		}
+	}
+	f.pos = append(f.pos, &Positional{name: name, help: help, arity: arity, flags: f})
+	return nil
+}
+
+// GetPositional returns Positional under specified name and a truth if it
+// exists.
+func (f *Flags) GetPositional(name string) (*Positional, bool) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.getpositional(name)
+}
+
+// getpositional is GetPositional for callers holding the lock.
+func (f *Flags) getpositional(name string) (*Positional, bool) {
+	for _, p := range f.pos {
+		if p.name == name {
+			return p, true
+		}
+	}
+	return nil, false
+}
+
+// Positionals returns defined positionals in order of definition.
+func (f *Flags) Positionals() []*Positional {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return append([]*Positional(nil), f.pos...)
+}
+
+// bindpositionals binds parsed operands to positionals defined in Flags
+// of the Result. If no positionals are defined operands are left unbound.
+func (r *Result) bindpositionals() error {
+	if len(r.flags.pos) == 0 {
+		return nil
+	}
+	args := r.args
+	for _, p := range r.flags.pos {
+		switch p.arity {
+		case ArityOne, ArityOptional:
+			if len(args) > 0 {
+				r.pos[p] = args[:1]
+				args = args[1:]
+			}
+		case ArityMany, ArityRest:
+			if len(args) > 0 {
+				r.pos[p] = args
+				args = nil
+			}
+		}
+		if p.Required() && len(r.pos[p]) == 0 {
+			if err := r.fail(r.flagerror(ErrPosRequired.WrapArgs(p.name), nil)); err != nil {
+				return err
+			}
+		}
+	}
+	if len(args) > 0 {
+		index := r.argidx[len(r.args)-len(args)]
+		return r.fail(r.newerror(ErrOperand.WrapArgs(args[0]), nil, index, args[0]))
+	}
+	return nil
+}
+
+// synopsis returns usage synopsis of Flags positionals.
+func (f *Flags) synopsis() string {
+	a := make([]string, 0, len(f.pos)+1)
+	if len(f.keys) > 0 {
+		a = append(a, "[flags]")
+	}
+	for _, p := range f.pos {
+		a = append(a, p.synopsis())
+	}
+	return strings.Join(a, " ")
+}
+
+// printpositionals prints positionals to w indented with indent.
+func (f *Flags) printpositionals(w io.Writer, indent string) {
+	for _, p := range f.pos {
+		fmt.Fprintf(w, "%s\t%s\t%s\t\n", indent, p.synopsis(), p.help)
+	}
+}
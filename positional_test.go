@@ -0,0 +1,111 @@
+// Copyright 2020 Vedran Vuk. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package flagex
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestDefinePositional(t *testing.T) {
+	f := New()
+	if err := f.DefinePositional("", "", ArityOne); !errors.Is(err, ErrInvalid) {
+		t.Fatal(err)
+	}
+	if err := f.DefinePositional("src", "source", ArityOne); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.DefinePositional("src", "source", ArityOne); !errors.Is(err, ErrDuplicate) {
+		t.Fatal(err)
+	}
+	if err := f.DefinePositional("dst", "destination", ArityOptional); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.DefinePositional("mode", "mode", ArityOne); !errors.Is(err, ErrPosOrder) {
+		t.Fatal(err)
+	}
+	if err := f.DefinePositional("files", "files", ArityRest); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.DefinePositional("more", "more", ArityRest); !errors.Is(err, ErrPosOrder) {
+		t.Fatal(err)
+	}
+}
+
+func TestPositionals(t *testing.T) {
+	f := New()
+	f.DefineSwitch("verbose", "v", "verbose output")
+	f.DefinePositional("src", "source file", ArityOne)
+	f.DefinePositional("dst", "destination files", ArityMany)
+
+	type Test struct {
+		Args     string
+		Src      string
+		Dst      []string
+		Expected error
+	}
+
+	tests := []Test{
+		{"a b", "a", []string{"b"}, nil},
+		{"-v a b c", "a", []string{"b", "c"}, nil},
+		{"a -v -- -b", "a", []string{"-b"}, nil},
+		{"-v", "", nil, ErrPosRequired},
+		{"-v a", "", nil, ErrRequired},
+		{"", "", nil, ErrPosRequired},
+	}
+
+	for _, test := range tests {
+		err := f.Parse(strings.Split(test.Args, " "))
+		if !errors.Is(err, test.Expected) {
+			t.Fatalf("'%s': expected '%v', got '%v'", test.Args, test.Expected, err)
+		}
+		if err != nil {
+			continue
+		}
+		src, _ := f.GetPositional("src")
+		dst, _ := f.GetPositional("dst")
+		if src.Value() != test.Src {
+			t.Fatalf("'%s': expected src '%s', got '%s'", test.Args, test.Src, src.Value())
+		}
+		if fmt.Sprint(dst.Values()) != fmt.Sprint(test.Dst) {
+			t.Fatalf("'%s': expected dst '%v', got '%v'", test.Args, test.Dst, dst.Values())
+		}
+	}
+
+	g := New()
+	g.DefinePositional("name", "name", ArityOne)
+	g.DefinePositional("alias", "alias", ArityOptional)
+	if err := g.Parse([]string{"a", "b", "c"}); !errors.Is(err, ErrOperand) {
+		t.Fatal(err)
+	}
+	if err := g.Parse([]string{"a"}); err != nil {
+		t.Fatal(err)
+	}
+	if p, _ := g.GetPositional("alias"); p.Parsed() {
+		t.Fatal("alias parsed")
+	}
+}
+
+func TestPositionalString(t *testing.T) {
+	f := New()
+	f.DefineSwitch("verbose", "v", "verbose output")
+	f.DefinePositional("src", "source file", ArityOne)
+	f.DefinePositional("dst", "destination file", ArityOptional)
+	f.DefinePositional("rest", "other files", ArityRest)
+	s := f.String()
+	if !strings.HasPrefix(s, "Usage: [flags] <src> [dst] [rest...]\n") {
+		t.Fatal(s)
+	}
+	for _, v := range []string{"<src>", "source file", "[dst]", "[rest...]"} {
+		if !strings.Contains(s, v) {
+			t.Fatalf("'%s' not in '%s'", v, s)
+		}
+	}
+	if verboseoutput {
+		fmt.Println(s)
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"encoding"
 	"fmt"
 	"reflect"
+	"strconv"
 	"strings"
 
 	"github.com/vedranvuk/errorex"
@@ -186,7 +187,7 @@ func structApplyFlags(flags *flagex.Flags, v reflect.Value) (*flagex.Flags, erro
 		var err error
 		if flag.Sub() == nil {
 			if flag.Kind() == flagex.KindSwitch {
-				err = reflectex.StringToValue("true", val)
+				err = reflectex.StringToValue(strconv.FormatBool(flag.Bool()), val)
 			} else {
 				err = reflectex.StringToValue(flag.Value(), val)
 			}
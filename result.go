@@ -0,0 +1,196 @@
+// Copyright 2020 Vedran Vuk. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package flagex
+
+// Result holds the result of parsing args against Flags, one level of the
+// Flags tree per Result. It references Flags it was parsed against and
+// their flags but never modifies them, so multiple Results of the same
+// Flags may be held and read at once.
+type Result struct {
+	flags   *Flags
+	parent  *Result
+	states  map[*Flag]*flagstate
+	pos     map[*Positional][]string
+	args    []string
+	unknown []string
+	sub     *Result
+	subflag *Flag
+	parsed  bool
+
+	// idx holds indexes in root args of args parsed in Result and argidx
+	// of its operands, cur and token the index and text of the arg being
+	// parsed and configfile the config file path. Root Result collects
+	// errors in errs if collect. negate is set if args are parsed using a
+	// syntax with a negate prefix and foreign if unknown flags were skipped
+	// or collected.
+	idx        []int
+	argidx     []int
+	cur        int
+	token      string
+	configfile string
+	collect    bool
+	errs       []*ParseError
+	negate     bool
+	foreign    bool
+}
+
+// newresult returns a new empty Result of Flags f with parent Result.
+func newresult(f *Flags, parent *Result) *Result {
+	return &Result{
+		flags:  f,
+		parent: parent,
+		states: make(map[*Flag]*flagstate),
+		pos:    make(map[*Positional][]string),
+	}
+}
+
+// get returns parse state of flag in Result.
+func (r *Result) get(flag *Flag) flagstate {
+	if s, ok := r.states[flag]; ok {
+		return *s
+	}
+	return flagstate{}
+}
+
+// state returns a modifiable parse state of flag in Result.
+func (r *Result) state(flag *Flag) *flagstate {
+	s, ok := r.states[flag]
+	if !ok {
+		s = &flagstate{}
+		r.states[flag] = s
+	}
+	return s
+}
+
+// operation returns the operation parsed in Result or nil if none.
+func (r *Result) operation() *Flag {
+	if r.subflag != nil && r.subflag.kind == KindOperation {
+		return r.subflag
+	}
+	return nil
+}
+
+// Flags returns Flags the Result was parsed against.
+func (r *Result) Flags() *Flags { return r.flags }
+
+// Sub returns the Result of a sub or an operation parsed in Result or nil
+// if none was parsed.
+func (r *Result) Sub() *Result { return r.sub }
+
+// Path returns keys of subs and operations parsed from Result down, in
+// order of nesting.
+func (r *Result) Path() []string {
+	var path []string
+	for ; r.sub != nil; r = r.sub {
+		path = append(path, r.subflag.key)
+	}
+	return path
+}
+
+// Args returns operands collected in Result in order of appearance.
+func (r *Result) Args() []string { return r.args }
+
+// Unknown returns unknown args and their params collected in Result in
+// order of appearance.
+func (r *Result) Unknown() []string { return r.unknown }
+
+// Positional returns operands bound to positional under specified name.
+func (r *Result) Positional(name string) []string {
+	r.flags.mu.RLock()
+	defer r.flags.mu.RUnlock()
+	if p, ok := r.flags.getpositional(name); ok {
+		return r.pos[p]
+	}
+	return nil
+}
+
+// Parsed returns if Result was parsed without errors if no keys are
+// specified. If one or more keys are specified, returns if all of the
+// specified keys were parsed.
+func (r *Result) Parsed(keys ...string) bool {
+	r.flags.mu.RLock()
+	defer r.flags.mu.RUnlock()
+	for _, key := range keys {
+		flag, ok := r.flags.getkey(key)
+		if !ok || !r.get(flag).parsed {
+			return false
+		}
+	}
+	return r.parsed
+}
+
+// Value returns value of flag under specified key as Flag.Value does or an
+// empty string if no such flag.
+func (r *Result) Value(key string) string {
+	r.flags.mu.RLock()
+	defer r.flags.mu.RUnlock()
+	if flag, ok := r.flags.getkey(key); ok {
+		return flag.valueof(r.get(flag))
+	}
+	return ""
+}
+
+// Values returns values of flag under specified key as Flag.Values does or
+// nil if no such flag.
+func (r *Result) Values(key string) []string {
+	r.flags.mu.RLock()
+	defer r.flags.mu.RUnlock()
+	if flag, ok := r.flags.getkey(key); ok {
+		return flag.valuesof(r.get(flag))
+	}
+	return nil
+}
+
+// Bool returns value of flag under specified key as a boolean as Flag.Bool
+// does or false if no such flag.
+func (r *Result) Bool(key string) bool {
+	r.flags.mu.RLock()
+	defer r.flags.mu.RUnlock()
+	if flag, ok := r.flags.getkey(key); ok {
+		return flag.boolof(r.get(flag))
+	}
+	return false
+}
+
+// Count returns the number of times flag under specified key was parsed.
+func (r *Result) Count(key string) int {
+	r.flags.mu.RLock()
+	defer r.flags.mu.RUnlock()
+	if flag, ok := r.flags.getkey(key); ok {
+		return r.get(flag).count
+	}
+	return 0
+}
+
+// ParseMap returns a map of parsed Flag key:value pairs.
+// Sub will return a map, Flags may return a string if parsed or
+// nil if not parsed. Repeatable Flags and Flags with a separator
+// return a slice of strings and counters return an int.
+func (r *Result) ParseMap() map[interface{}]interface{} {
+	r.flags.mu.RLock()
+	defer r.flags.mu.RUnlock()
+	ret := make(map[interface{}]interface{})
+	for _, flag := range r.flags.keys {
+		s := r.get(flag)
+		if !s.parsed {
+			continue
+		}
+		switch {
+		case flag.sub != nil:
+			if flag == r.subflag {
+				ret[flag.key] = r.sub.ParseMap()
+			}
+		case flag.kind == KindCounter:
+			ret[flag.key] = s.count
+		case s.parsedval && (flag.repeatable || flag.sep != ""):
+			ret[flag.key] = flag.valuesof(s)
+		case s.parsedval:
+			ret[flag.key] = flag.valueof(s)
+		default:
+			ret[flag.key] = nil
+		}
+	}
+	return ret
+}
@@ -0,0 +1,114 @@
+// Copyright 2020 Vedran Vuk. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package flagex
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestEvaluate(t *testing.T) {
+	sub := New()
+	sub.DefineOptional("addr", "a", "listen address", "address", ":80")
+	sub.DefineCounter("verbose", "v", "verbosity")
+	sub.DefinePositional("root", "root directory", ArityOptional)
+	f := New()
+	f.DefineSwitch("debug", "d", "debug mode")
+	f.DefineOptional("include", "I", "include path", "path", "")
+	f.DefineSub("serve", "s", "serve", sub)
+	inc, _ := f.GetKey("include")
+	inc.SetRepeatable(true)
+
+	r, err := f.Evaluate(strings.Split("-d -I a -I b file -s -vv --addr :8080 www", " "))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Parsed() || f.Parsed("debug") || f.Args() != nil {
+		t.Fatal("Evaluate modified Flags")
+	}
+	if !r.Parsed("debug", "include", "serve") || !r.Bool("debug") {
+		t.Fatal("parsed state failed")
+	}
+	if v := r.Values("include"); fmt.Sprint(v) != "[a b]" {
+		t.Fatalf("values failed: %v", v)
+	}
+	if fmt.Sprint(r.Args()) != "[file]" || fmt.Sprint(r.Path()) != "[serve]" {
+		t.Fatalf("args or path failed: %v %v", r.Args(), r.Path())
+	}
+	sr := r.Sub()
+	if sr == nil || sr.Flags() != f.keys["serve"].Sub() {
+		t.Fatal("sub result failed")
+	}
+	if sr.Value("addr") != ":8080" || sr.Count("verbose") != 2 || sr.Value("verbose") != "2" {
+		t.Fatalf("sub values failed: %v", sr.ParseMap())
+	}
+	if fmt.Sprint(sr.Positional("root")) != "[www]" {
+		t.Fatal("positional failed")
+	}
+
+	if err := f.Parse([]string{"-s", "-a", ":443"}); err != nil {
+		t.Fatal(err)
+	}
+	if f.Parsed("debug") || !f.Parsed("serve") || f.GetValue("include") != "" {
+		t.Fatal("Parse failed")
+	}
+	if sr.Value("addr") != ":8080" || !r.Parsed("debug") {
+		t.Fatal("Parse modified Result")
+	}
+
+	r, err = f.Evaluate([]string{"-x"})
+	if !errors.Is(err, ErrNotFound) || r != nil {
+		t.Fatal(err)
+	}
+	r, err = f.Evaluate([]string{"-d"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Sub() != nil || r.Path() != nil || r.Value("include") != "" {
+		t.Fatal("unparsed sub failed")
+	}
+	if sr := f.keys["serve"].Sub(); sr.GetValue("addr") != ":443" {
+		t.Fatal("Evaluate modified sub")
+	}
+}
+
+func TestEvaluateConcurrent(t *testing.T) {
+	sub := New()
+	sub.DefineOptional("addr", "a", "listen address", "address", ":80")
+	sub.DefineCounter("verbose", "v", "verbosity")
+	sub.DefinePositional("root", "root directory", ArityOptional)
+	f := New()
+	f.DefineSwitch("debug", "d", "debug mode")
+	f.DefineOptional("include", "I", "include path", "path", "")
+	f.DefineSub("serve", "s", "serve", sub)
+	inc, _ := f.GetKey("include")
+	inc.SetRepeatable(true)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 64)
+	for i := 0; i < cap(errs); i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			addr := fmt.Sprintf(":%d", i)
+			r, err := f.Evaluate([]string{"-I", addr, "-s", "-a", addr})
+			if err != nil {
+				errs <- err
+				return
+			}
+			if r.Value("include") != addr || r.Sub().Value("addr") != addr {
+				errs <- fmt.Errorf("%d: got '%s' and '%s'", i, r.Value("include"), r.Sub().Value("addr"))
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+}
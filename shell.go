@@ -0,0 +1,198 @@
+// Copyright 2020 Vedran Vuk. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package flagex
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Token is an arg split from a command string by Tokenizer.
+type Token struct {
+	// Text is the arg with quotes and escapes removed and variables
+	// expanded.
+	Text string
+	// Line and Column are the 1-based line and column, in runes, where the
+	// arg starts in the command string.
+	Line, Column int
+	// Quoted is true if any part of the arg was quoted or escaped.
+	Quoted bool
+}
+
+// Tokenizer splits command strings into args as a POSIX shell does.
+//
+// Args are separated by unquoted whitespace. Characters enclosed in single
+// quotes are taken literally. Characters enclosed in double quotes are taken
+// literally except for a backslash which escapes a following '"', '\', '$',
+// '`' or a newline and '$' which starts a variable if expansion is enabled.
+// An unquoted backslash escapes the following character and a backslash
+// followed by a newline is removed. An unquoted '#' starting an arg starts a
+// comment which extends to the end of the line.
+type Tokenizer struct {
+	// Expand, if not nil, enables expansion of variables given as "$NAME"
+	// or "${NAME}" outside of single quotes to values Expand returns for
+	// them, e.g. os.Getenv. Expanded values are not split into args and an
+	// unquoted variable expanded to an empty string yields no arg by itself.
+	Expand func(name string) string
+}
+
+// Split splits s into args using a Tokenizer with no variable expansion.
+func Split(s string) ([]string, error) {
+	return Tokenizer{}.Split(s)
+}
+
+// Split splits s into args. It returns ErrSplit pointing at the line and
+// column of an unterminated quote or escape or an invalid variable.
+func (t Tokenizer) Split(s string) ([]string, error) {
+	tokens, err := t.Tokens(s)
+	if err != nil {
+		return nil, err
+	}
+	args := make([]string, 0, len(tokens))
+	for _, token := range tokens {
+		args = append(args, token.Text)
+	}
+	return args, nil
+}
+
+// Tokens splits s into args as Split does but returns them as Tokens.
+func (t Tokenizer) Tokens(s string) ([]Token, error) {
+	tokens, serr := t.tokens(s)
+	if serr != nil {
+		return nil, ErrSplit.WrapArgs(serr.line, serr.column, serr.msg)
+	}
+	return tokens, nil
+}
+
+// spliterr describes where and why splitting failed.
+type spliterr struct {
+	line, column int
+	msg          string
+}
+
+// tokens splits s into Tokens or returns where and why it failed.
+func (t Tokenizer) tokens(s string) ([]Token, *spliterr) {
+	var tokens []Token
+	var token *Token
+	var buf strings.Builder
+	line, column := 1, 0
+	var quote rune
+	var qline, qcolumn int
+	start := func(quoted bool) {
+		if token == nil {
+			token = &Token{Line: line, Column: column}
+		}
+		token.Quoted = token.Quoted || quoted
+	}
+	flush := func() {
+		if token != nil {
+			token.Text = buf.String()
+			tokens = append(tokens, *token)
+			token = nil
+			buf.Reset()
+		}
+	}
+	runes := []rune(s)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		column++
+		switch {
+		case quote == '\'':
+			if r == '\'' {
+				quote = 0
+			} else {
+				buf.WriteRune(r)
+			}
+		case r == '\\' && (quote == 0 || i+1 < len(runes) && strings.ContainsRune("\"\\$`\n", runes[i+1])):
+			if i+1 == len(runes) {
+				return nil, &spliterr{line, column, "unterminated escape"}
+			}
+			if runes[i+1] != '\n' {
+				start(true)
+				buf.WriteRune(runes[i+1])
+			}
+			i++
+			column++
+		case r == '$' && t.Expand != nil:
+			name, n, ok := varname(runes[i+1:])
+			if !ok {
+				return nil, &spliterr{line, column, "invalid variable"}
+			}
+			if n == 0 {
+				start(false)
+				buf.WriteRune(r)
+				continue
+			}
+			if value := t.Expand(name); value != "" || quote != 0 {
+				start(false)
+				buf.WriteString(value)
+			}
+			i += n
+			column += n
+		case quote == '"':
+			if r == '"' {
+				quote = 0
+			} else {
+				buf.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			start(true)
+			quote, qline, qcolumn = r, line, column
+		case unicode.IsSpace(r):
+			flush()
+		case r == '#' && token == nil:
+			for i+1 < len(runes) && runes[i+1] != '\n' {
+				i++
+			}
+		default:
+			start(false)
+			buf.WriteRune(r)
+		}
+		if r == '\n' || i > 0 && runes[i-1] == '\\' && runes[i] == '\n' {
+			line, column = line+1, 0
+		}
+	}
+	if quote != 0 {
+		return nil, &spliterr{qline, qcolumn, "unterminated quote"}
+	}
+	flush()
+	return tokens, nil
+}
+
+// varname returns the name of a variable at the start of runes following a
+// '$' and the number of runes it spans, including braces. It returns zero
+// length if runes do not start with a variable and false if a braced name
+// is empty, invalid or not terminated.
+func varname(runes []rune) (string, int, bool) {
+	isname := func(r rune, first bool) bool {
+		return r == '_' || unicode.IsLetter(r) || !first && unicode.IsDigit(r)
+	}
+	if len(runes) > 0 && runes[0] == '{' {
+		for i := 1; i < len(runes); i++ {
+			if runes[i] == '}' && i > 1 {
+				return string(runes[1:i]), i + 1, true
+			}
+			if !isname(runes[i], i == 1) {
+				break
+			}
+		}
+		return "", 0, false
+	}
+	n := 0
+	for n < len(runes) && isname(runes[n], n == 0) {
+		n++
+	}
+	return string(runes[:n]), n, true
+}
+
+// ParseString splits s into args with Split and parses them with Parse.
+// Use Tokenizer to split command strings with variable expansion.
+func (f *Flags) ParseString(s string) error {
+	args, err := Split(s)
+	if err != nil {
+		return err
+	}
+	return f.Parse(args)
+}
@@ -0,0 +1,86 @@
+// Copyright 2020 Vedran Vuk. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package flagex
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestSplit(t *testing.T) {
+	expand := func(name string) string {
+		return map[string]string{"HOME": "/home/u", "SP": "a b"}[name]
+	}
+	type Test struct {
+		In       string
+		Expected string
+		Expand   bool
+	}
+	tests := []Test{
+		{"", "", false},
+		{"  -a  --b=c \t d  ", "-a|--b=c|d", false},
+		{`'single "q" \' a\ b`, `single "q" \|a b`, false},
+		{`"double \"q\" \a \$ \\" ''`, `double "q" \a $ \|`, false},
+		{"a\\\nb # comment\nc#d", "ab|c#d", false},
+		{`$HOME "$HOME/x" '$HOME'`, `$HOME|$HOME/x|$HOME`, false},
+		{`$HOME "${HOME}/x" '$HOME' $SP`, `/home/u|/home/u/x|$HOME|a b`, true},
+		{`$NONE "$NONE" x$NONE $ 1$`, `|x|$|1$`, true},
+	}
+	for _, test := range tests {
+		tokenizer := Tokenizer{}
+		if test.Expand {
+			tokenizer.Expand = expand
+		}
+		args, err := tokenizer.Split(test.In)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s := strings.Join(args, "|"); s != test.Expected {
+			t.Fatalf("'%s': expected '%s', got '%s'", test.In, test.Expected, s)
+		}
+	}
+
+	tokens, err := Tokenizer{}.Tokens("-a\n  'b\nc' \\\n d")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tokens) != 3 || tokens[1].Line != 2 || tokens[1].Column != 3 || !tokens[1].Quoted ||
+		tokens[2].Line != 4 || tokens[2].Column != 2 || tokens[2].Quoted {
+		t.Fatalf("positions failed: %v", tokens)
+	}
+
+	errtests := map[string]string{
+		"a 'b":            "1:3:",
+		"a\n  b \"c\\\"":  "2:5:",
+		"a \\":            "1:3:",
+		"a ${B":           "1:3:",
+		"a\n'b' ${}":      "2:5:",
+		"a ${B C}":        "1:3:",
+		"# 'comment\n\"x": "2:1:",
+		"a\\\nb\\\n'c":    "3:1:",
+	}
+	for in, position := range errtests {
+		_, err := Tokenizer{Expand: expand}.Split(in)
+		if !errors.Is(err, ErrSplit) || !strings.Contains(err.Error(), position) {
+			t.Fatalf("'%s': expected error at '%s', got '%v'", in, position, err)
+		}
+	}
+}
+
+func TestParseString(t *testing.T) {
+	f := New()
+	f.DefineSwitch("verbose", "v", "verbose")
+	f.DefineOptional("output", "o", "output", "file", "")
+	if err := f.ParseString(`-v --output "my file" 'x y'`); err != nil {
+		t.Fatal(err)
+	}
+	if f.GetValue("output") != "my file" || len(f.Args()) != 1 || f.Args()[0] != "x y" {
+		t.Fatalf("parse string failed: %v %v", f.ParseMap(), f.Args())
+	}
+	if err := f.ParseString(`-v --output "my file`); !errors.Is(err, ErrSplit) {
+		t.Fatal(err)
+	}
+}
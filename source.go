@@ -0,0 +1,188 @@
+// Copyright 2020 Vedran Vuk. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package flagex
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SourceKind specifies where a Flag value came from.
+type SourceKind byte
+
+const (
+	// SourceDefault marks a value as the default value of a flag that was
+	// not parsed.
+	SourceDefault SourceKind = iota
+	// SourceEnv marks a value as read from an environment variable.
+	SourceEnv
+	// SourceConfig marks a value as read from a config file.
+	SourceConfig
+	// SourceArgs marks a value as parsed from args.
+	SourceArgs
+	// SourceProgram marks a value as set with Flags.Set.
+	SourceProgram
+	// SourceImplied marks a value as implied by another parsed flag, see
+	// Implies.
+	SourceImplied
+)
+
+// String implements Stringer interface on SourceKind.
+func (sk SourceKind) String() string {
+	switch sk {
+	case SourceDefault:
+		return "default"
+	case SourceEnv:
+		return "env"
+	case SourceConfig:
+		return "config"
+	case SourceArgs:
+		return "args"
+	case SourceProgram:
+		return "program"
+	case SourceImplied:
+		return "implied"
+	}
+	return ""
+}
+
+// Source describes where a Flag value came from.
+type Source struct {
+	// Kind is the kind of the source.
+	Kind SourceKind
+	// Name is the environment variable name for SourceEnv, the config
+	// file path for SourceConfig or the key of the implying flag for
+	// SourceImplied.
+	Name string
+	// Index is the index of the arg in args given to Parse the flag was
+	// parsed from for SourceArgs or -1 otherwise. Flags read from an args
+	// file have the index of the arg naming the file.
+	Index int
+}
+
+// String implements Stringer interface on Source.
+func (s Source) String() string {
+	switch s.Kind {
+	case SourceEnv, SourceConfig, SourceImplied:
+		return s.Kind.String() + " " + s.Name
+	case SourceArgs:
+		return fmt.Sprintf("%s[%d]", s.Kind, s.Index)
+	}
+	return s.Kind.String()
+}
+
+// sourceof returns value source given parse state s.
+func sourceof(s flagstate) Source {
+	if !s.parsed && !s.implied {
+		return Source{Kind: SourceDefault, Index: -1}
+	}
+	return s.source
+}
+
+// Source returns where current Flag value came from.
+func (f *Flag) Source() Source {
+	f.flags.mu.RLock()
+	defer f.flags.mu.RUnlock()
+	return sourceof(f.state())
+}
+
+// Source returns where value of flag under specified key came from as
+// Flag.Source does or a default Source if no such flag.
+func (r *Result) Source(key string) Source {
+	r.flags.mu.RLock()
+	defer r.flags.mu.RUnlock()
+	if flag, ok := r.flags.getkey(key); ok {
+		return sourceof(r.get(flag))
+	}
+	return sourceof(flagstate{})
+}
+
+// Set sets value of flag under specified key in the result of last Parse
+// as if parsed, replacing any values the flag had. A switch takes a boolean
+// and a counter a non-negative count. Subs and operations cannot be set.
+func (f *Flags) Set(key, value string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	flag, ok := f.getkey(key)
+	if !ok {
+		return ErrNotFound.WrapArgs(key)
+	}
+	if flag.sub != nil {
+		return ErrValue.WrapArgs(value, key)
+	}
+	prev := f.result.get(flag)
+	delete(f.result.states, flag)
+	valid, err := f.result.consumefallback(flag, value, Source{Kind: SourceProgram, Index: -1})
+	if err == nil && !valid {
+		err = ErrValue.WrapArgs(value, key)
+	}
+	if err != nil {
+		*f.result.state(flag) = prev
+	}
+	return err
+}
+
+// Entry is a flag value in a dump of a Result.
+type Entry struct {
+	// Key is the flag key prefixed with keys of parent subs and operations
+	// separated by dots.
+	Key string
+	// Value is the flag value as returned by Flag.Value.
+	Value string
+	// Values are the flag values as returned by Flag.Values.
+	Values []string
+	// Source is where the value came from.
+	Source Source
+}
+
+// String implements Stringer interface on Entry.
+func (e Entry) String() string {
+	value := e.Value
+	if len(e.Values) > 1 {
+		value = strings.Join(e.Values, ",")
+	}
+	return fmt.Sprintf("%s=%s (%s)", e.Key, value, e.Source)
+}
+
+// Dump returns values and their sources of all flags in Result and the
+// Results of subs and operations parsed in it, sorted by key with each sub
+// or operation followed by its flags. Subs and operations are
+// included if parsed.
+func (r *Result) Dump() []Entry {
+	return r.dump("")
+}
+
+// dump returns Dump entries with keys prefixed with prefix.
+func (r *Result) dump(prefix string) []Entry {
+	r.flags.mu.RLock()
+	defer r.flags.mu.RUnlock()
+	var entries []Entry
+	for _, flag := range r.flags.sorted() {
+		s := r.get(flag)
+		if flag.sub != nil {
+			if flag == r.subflag {
+				entries = append(entries, Entry{Key: prefix + flag.key, Source: sourceof(s)})
+				entries = append(entries, r.sub.dump(prefix+flag.key+".")...)
+			}
+			continue
+		}
+		entries = append(entries, Entry{
+			Key:    prefix + flag.key,
+			Value:  flag.valueof(s),
+			Values: flag.valuesof(s),
+			Source: sourceof(s),
+		})
+	}
+	return entries
+}
+
+// Dump returns values and their sources of all flags from last Parse as
+// Result.Dump does.
+func (f *Flags) Dump() []Entry {
+	f.mu.RLock()
+	r := f.result
+	f.mu.RUnlock()
+	return r.Dump()
+}
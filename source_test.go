@@ -0,0 +1,87 @@
+// Copyright 2020 Vedran Vuk. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package flagex
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSource(t *testing.T) {
+	dir := writeargsfiles(t, map[string]string{
+		"config.json": `{"output": "cfg.out", "srvparams": {"tls": "v1"}}`,
+		"more.args":   "-I a -I b",
+	})
+	defer os.RemoveAll(dir)
+	config := filepath.Join(dir, "config.json")
+	sub := New()
+	sub.DefineOptional("addr", "a", "listen address", "address", ":80")
+	sub.DefineOptional("tls", "t", "tls mode", "mode", "v3")
+	f := New()
+	f.DefineOptional("config", "c", "config file", "filename", "")
+	f.DefineSwitch("verbose", "v", "verbose")
+	f.DefineCounter("level", "l", "level")
+	f.DefineRequired("output", "o", "output", "filename", "")
+	f.DefineOptional("include", "I", "include", "path", "")
+	f.DefineSub("srvparams", "s", "server params", sub)
+	inc, _ := f.GetKey("include")
+	inc.SetRepeatable(true)
+	f.SetConfig(Config{Key: "config"})
+	f.SetEnvPrefix("FLAGEXCFG")
+	f.SetArgsFiles(true)
+	defer setenv(t, map[string]string{"FLAGEXCFG_LEVEL": "2"})()
+
+	args := []string{"-v", "@" + filepath.Join(dir, "more.args"), "-c", config, "-s", "--addr", ":8080"}
+	if err := f.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"config=" + config + " (args[2])",
+		"include=a,b (args[1])",
+		"level=2 (env FLAGEXCFG_LEVEL)",
+		"output=cfg.out (config " + config + ")",
+		"srvparams= (args[4])",
+		"srvparams.addr=:8080 (args[5])",
+		"srvparams.tls=v1 (config " + config + ")",
+		"verbose= (args[0])",
+	}
+	entries := f.Dump()
+	if len(entries) != len(expected) {
+		t.Fatalf("dump failed: %v", entries)
+	}
+	for i, entry := range entries {
+		if entry.String() != expected[i] {
+			t.Fatalf("expected '%s', got '%s'", expected[i], entry)
+		}
+	}
+
+	if err := f.Parse([]string{"-o", "x"}); err != nil {
+		t.Fatal(err)
+	}
+	if s := inc.Source(); s.Kind != SourceDefault || s.Index != -1 {
+		t.Fatalf("default source failed: %v", s)
+	}
+	if err := f.Set("include", "c"); err != nil {
+		t.Fatal(err)
+	}
+	if inc.Source().Kind != SourceProgram || inc.Value() != "c" {
+		t.Fatalf("set failed: %v", inc.Source())
+	}
+	if err := f.Set("level", "-1"); !errors.Is(err, ErrValue) {
+		t.Fatal(err)
+	}
+	if s := fmt.Sprint(f.result.Source("level")); s != "env FLAGEXCFG_LEVEL" {
+		t.Fatalf("failed set changed value: %s", s)
+	}
+	if err := f.Set("srvparams", ""); !errors.Is(err, ErrValue) {
+		t.Fatal(err)
+	}
+	if err := f.Set("nope", ""); !errors.Is(err, ErrNotFound) {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,152 @@
+// Copyright 2020 Vedran Vuk. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package flagex
+
+import (
+	"errors"
+	"sort"
+	"strings"
+
+	"github.com/vedranvuk/errorex"
+)
+
+// maxsuggestions is the maximum number of suggested flags.
+const maxsuggestions = 3
+
+// Suggestions returns flags suggested by an ErrDidYouMean in the chain of
+// err, as they would be given in args, or nil if none.
+func Suggestions(err error) []string {
+	var ee *errorex.ErrorEx
+	if !errors.As(err, &ee) {
+		return nil
+	}
+	suggestions, _ := ee.AnyData().([]string)
+	return suggestions
+}
+
+// notfound returns ErrNotFound for unknown arg or ErrDidYouMean if flags
+// similar to arg were found.
+func (f *Flags) notfound(arg string, opts parseopts) error {
+	suggestions := f.suggest(arg, opts)
+	if len(suggestions) == 0 {
+		return ErrNotFound.WrapArgs(arg)
+	}
+	quoted := make([]string, 0, len(suggestions))
+	for _, s := range suggestions {
+		quoted = append(quoted, "'"+s+"'")
+	}
+	s := quoted[len(quoted)-1]
+	if len(quoted) > 1 {
+		s = strings.Join(quoted[:len(quoted)-1], ", ") + " or " + s
+	}
+	return ErrDidYouMean.WrapDataArgs(suggestions, arg, s)
+}
+
+// suggest returns keys and shortkeys of flags, subs and operations in
+// Flags and, if parsing operation options, in Flags defining the operation,
+// that are similar to unknown arg. They are prefixed as in args and ordered
+// by similarity.
+func (f *Flags) suggest(arg string, opts parseopts) []string {
+	syntax := opts.syntax
+	key, _, _ := syntax.split(arg)
+	switch {
+	case syntax.islong(key):
+		key = strings.TrimPrefix(key, syntax.Long)
+	case strings.HasPrefix(key, syntax.Short):
+		key = strings.TrimPrefix(key, syntax.Short)
+	case syntax.Negate != "":
+		key = strings.TrimPrefix(key, syntax.Negate)
+	}
+	if key == "" {
+		return nil
+	}
+	type suggestion struct {
+		text     string
+		distance int
+	}
+	var suggestions []suggestion
+	seen := make(map[string]bool)
+	add := func(flags *Flags) {
+		for _, flag := range flags.keys {
+			text := syntax.Long + flag.key
+			if d, ok := similar(key, flag.key); ok && !seen[text] {
+				suggestions = append(suggestions, suggestion{text, d})
+				seen[text] = true
+			}
+			text = syntax.Short + flag.shortkey
+			if flag.shortkey != "" && key != flag.shortkey && strings.EqualFold(key, flag.shortkey) && !seen[text] {
+				suggestions = append(suggestions, suggestion{text, 1})
+				seen[text] = true
+			}
+		}
+	}
+	add(f)
+	if opts.op != nil {
+		add(opts.op.flags)
+	}
+	sort.Slice(suggestions, func(i, j int) bool {
+		if suggestions[i].distance != suggestions[j].distance {
+			return suggestions[i].distance < suggestions[j].distance
+		}
+		return suggestions[i].text < suggestions[j].text
+	})
+	var result []string
+	for i := 0; i < len(suggestions) && i < maxsuggestions; i++ {
+		result = append(result, suggestions[i].text)
+	}
+	return result
+}
+
+// similar returns the edit distance between unknown key and a defined key
+// and if they are similar enough for key to be suggested. Keys are similar
+// if one can be made from the other by changing at most a third of its
+// characters, or at least one, or if key is an abbreviation of at least
+// three characters of a defined key.
+func similar(unknown, key string) (int, bool) {
+	unknown, key = strings.ToLower(unknown), strings.ToLower(key)
+	d := distance(unknown, key)
+	if len(key) < 2 {
+		return d, false
+	}
+	max := len(key) / 3
+	if max < 1 {
+		max = 1
+	}
+	return d, d <= max || len(unknown) >= 3 && strings.HasPrefix(key, unknown)
+}
+
+// distance returns the optimal string alignment distance between a and b,
+// the number of insertions, deletions, substitutions and transpositions of
+// adjacent runes needed to turn a into b.
+func distance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	d := make([][]int, len(ra)+1)
+	for i := range d {
+		d[i] = make([]int, len(rb)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+	min := func(a, b int) int {
+		if a < b {
+			return a
+		}
+		return b
+	}
+	for i := 1; i <= len(ra); i++ {
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			d[i][j] = min(min(d[i-1][j]+1, d[i][j-1]+1), d[i-1][j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				d[i][j] = min(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+	return d[len(ra)][len(rb)]
+}
@@ -0,0 +1,58 @@
+// Copyright 2020 Vedran Vuk. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package flagex
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestSuggestions(t *testing.T) {
+	sub := New()
+	sub.DefineOptional("addr", "a", "listen address", "address", "")
+	f := New()
+	f.DefineSwitch("verbose", "v", "verbose")
+	f.DefineSwitch("verbatim", "V", "verbatim")
+	f.DefineOptional("output", "o", "output", "file", "")
+	f.DefineSub("srvparams", "s", "server params", sub)
+	f.DefineOperation("sync", "S", "sync", New())
+
+	type Test struct {
+		Args     string
+		Expected string
+	}
+	tests := []Test{
+		{"--verbos", "--verbose"},
+		{"--vrebose", "--verbose"},
+		{"--verb", "--verbose,--verbatim"},
+		{"-verbose", "--verbose"},
+		{"--outptu=x", "--output"},
+		{"--SrvParam", "--srvparams"},
+		{"-O", "-o"},
+		{"--snyc", "--sync"},
+		{"--sync --outptu", "--output"},
+		{"--srvparams --adr :80", "--addr"},
+		{"--nothing", ""},
+		{"-x", ""},
+	}
+	for _, test := range tests {
+		err := f.Parse(strings.Split(test.Args, " "))
+		if !errors.Is(err, ErrNotFound) {
+			t.Fatalf("'%s': expected ErrNotFound, got '%v'", test.Args, err)
+		}
+		if s := strings.Join(Suggestions(err), ","); s != test.Expected {
+			t.Fatalf("'%s': expected suggestions '%s', got '%s'", test.Args, test.Expected, s)
+		}
+		if errors.Is(err, ErrDidYouMean) != (test.Expected != "") {
+			t.Fatalf("'%s': unexpected error '%v'", test.Args, err)
+		}
+	}
+
+	err := f.Parse([]string{"--verb"})
+	if s := err.Error(); !strings.HasSuffix(s, "key '--verb' not found, did you mean '--verbose' or '--verbatim'?") {
+		t.Fatalf("unexpected message '%s'", s)
+	}
+}
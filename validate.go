@@ -0,0 +1,195 @@
+// Copyright 2020 Vedran Vuk. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package flagex
+
+import (
+	"net"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Validator validates a flag value and returns an error if it is invalid.
+type Validator func(value string) error
+
+// Normalizer returns a flag value in normalized form.
+type Normalizer func(value string) string
+
+// SetValidators sets validators of flag values. Each value parsed from args,
+// environment or config as well as the default value is validated, after
+// normalization and choice matching, by validators in order. If a validator
+// rejects a value, Parse returns ErrValue with the validator error as its
+// cause. If flag has a separator set, each separated value is validated.
+// Validators do not apply to switches, counters, subs and operations.
+func (f *Flag) SetValidators(validators ...Validator) {
+	f.flags.mu.Lock()
+	defer f.flags.mu.Unlock()
+	f.validators = append([]Validator(nil), validators...)
+}
+
+// Validators returns validators of flag values.
+func (f *Flag) Validators() []Validator {
+	f.flags.mu.RLock()
+	defer f.flags.mu.RUnlock()
+	return append([]Validator(nil), f.validators...)
+}
+
+// SetNormalizers sets normalizers of flag values. Each value parsed from
+// args, environment or config as well as the default value is normalized by
+// normalizers in order before it is matched against choices and validated.
+// If flag has a separator set, each separated value is normalized.
+// Normalizers do not apply to switches, counters, subs and operations.
+func (f *Flag) SetNormalizers(normalizers ...Normalizer) {
+	f.flags.mu.Lock()
+	defer f.flags.mu.Unlock()
+	f.normalizers = append([]Normalizer(nil), normalizers...)
+}
+
+// Normalizers returns normalizers of flag values.
+func (f *Flag) Normalizers() []Normalizer {
+	f.flags.mu.RLock()
+	defer f.flags.mu.RUnlock()
+	return append([]Normalizer(nil), f.normalizers...)
+}
+
+// process returns value with each of its separated values normalized and
+// matched against Flag choices or an error if any of them is not a choice or
+// is rejected by a validator.
+func (f *Flag) process(value string) (string, error) {
+	if f.isswitch() || f.sub != nil {
+		return value, nil
+	}
+	if len(f.normalizers) == 0 && len(f.choices) == 0 && len(f.validators) == 0 {
+		return value, nil
+	}
+	values := f.split(value)
+	for i, v := range values {
+		for _, normalize := range f.normalizers {
+			v = normalize(v)
+		}
+		if len(f.choices) > 0 {
+			c, ok := f.choose(v)
+			if !ok {
+				return "", ErrChoice.WrapArgs(v, f.key, f.choicelist(", "))
+			}
+			v = c
+		}
+		for _, validate := range f.validators {
+			if err := validate(v); err != nil {
+				return "", ErrValue.WrapCauseArgs(err, v, f.key)
+			}
+		}
+		values[i] = v
+	}
+	return strings.Join(values, f.sep), nil
+}
+
+// defvalue returns Flag default value processed as parsed values are or as
+// is if it is invalid.
+func (f *Flag) defvalue() string {
+	if f.defval == "" {
+		return ""
+	}
+	value, err := f.process(f.defval)
+	if err != nil {
+		return f.defval
+	}
+	return value
+}
+
+// TrimSpace is a Normalizer that removes leading and trailing white space.
+func TrimSpace(value string) string { return strings.TrimSpace(value) }
+
+// Lowercase is a Normalizer that converts value to lower case.
+func Lowercase(value string) string { return strings.ToLower(value) }
+
+// CleanPath is a Normalizer that cleans value as a file path with
+// filepath.Clean. An empty value is left empty.
+func CleanPath(value string) string {
+	if value == "" {
+		return ""
+	}
+	return filepath.Clean(value)
+}
+
+// NonEmpty is a Validator that rejects an empty value, e.g. one that was
+// trimmed to empty by a normalizer, with ErrEmpty.
+func NonEmpty(value string) error {
+	if value == "" {
+		return ErrEmpty
+	}
+	return nil
+}
+
+// IntRange returns a Validator that rejects values which are not integers
+// between min and max inclusive with ErrRange. Integers may be given in any
+// base accepted by strconv.ParseInt with base 0, e.g. "0x1f".
+func IntRange(min, max int64) Validator {
+	return func(value string) error {
+		n, err := strconv.ParseInt(value, 0, 64)
+		if err != nil || n < min || n > max {
+			return ErrRange.WrapArgs(value, min, max)
+		}
+		return nil
+	}
+}
+
+// FloatRange returns a Validator that rejects values which are not numbers
+// between min and max inclusive with ErrRange.
+func FloatRange(min, max float64) Validator {
+	return func(value string) error {
+		n, err := strconv.ParseFloat(value, 64)
+		if err != nil || n < min || n > max {
+			return ErrRange.WrapArgs(value, min, max)
+		}
+		return nil
+	}
+}
+
+// Matches returns a Validator that rejects values which do not match re
+// with ErrMatch.
+func Matches(re *regexp.Regexp) Validator {
+	return func(value string) error {
+		if !re.MatchString(value) {
+			return ErrMatch.WrapArgs(value, re.String())
+		}
+		return nil
+	}
+}
+
+// ValidURL is a Validator that rejects values which are not absolute URLs
+// with ErrURL.
+func ValidURL(value string) error {
+	u, err := url.Parse(value)
+	if err != nil || u.Scheme == "" || u.Host == "" && u.Opaque == "" {
+		return ErrURL.WrapArgs(value)
+	}
+	return nil
+}
+
+// ValidHostPort is a Validator that rejects values which are not a host,
+// possibly empty, and a numeric port separated by a colon, e.g.
+// "localhost:80", "[::1]:80" or ":80", with ErrHostPort.
+func ValidHostPort(value string) error {
+	_, port, err := net.SplitHostPort(value)
+	if err != nil {
+		return ErrHostPort.WrapArgs(value)
+	}
+	if _, err := strconv.ParseUint(port, 10, 16); err != nil {
+		return ErrHostPort.WrapArgs(value)
+	}
+	return nil
+}
+
+// ValidIP is a Validator that rejects values which are not IPv4 or IPv6
+// addresses with ErrIP.
+func ValidIP(value string) error {
+	if net.ParseIP(value) == nil {
+		return ErrIP.WrapArgs(value)
+	}
+	return nil
+}
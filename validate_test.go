@@ -0,0 +1,104 @@
+// Copyright 2020 Vedran Vuk. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package flagex
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestValidators(t *testing.T) {
+	type Test struct {
+		Validator Validator
+		Valid     string
+		Invalid   string
+		Expected  error
+	}
+	tests := []Test{
+		{NonEmpty, "x", "", ErrEmpty},
+		{IntRange(1, 65535), "0x50", "65536", ErrRange},
+		{IntRange(-5, 5), "-5", "1.5", ErrRange},
+		{FloatRange(0, 1), "0.5", "1.01", ErrRange},
+		{Matches(regexp.MustCompile(`^[a-z]+$`)), "abc", "ab1", ErrMatch},
+		{ValidURL, "https://example.com/x", "/relative/path", ErrURL},
+		{ValidURL, "mailto:me@example.com", "example.com", ErrURL},
+		{ValidHostPort, "[::1]:8080", "localhost", ErrHostPort},
+		{ValidHostPort, ":80", "host:http", ErrHostPort},
+		{ValidIP, "::1", "256.1.1.1", ErrIP},
+	}
+	for i, test := range tests {
+		if err := test.Validator(test.Valid); err != nil {
+			t.Fatalf("%d: '%s' rejected: %v", i, test.Valid, err)
+		}
+		if err := test.Validator(test.Invalid); !errors.Is(err, test.Expected) {
+			t.Fatalf("%d: '%s': expected '%v', got '%v'", i, test.Invalid, test.Expected, err)
+		}
+	}
+	if v := CleanPath("a/../b//c/"); v != "b/c" {
+		t.Fatalf("clean path failed: %s", v)
+	}
+}
+
+func TestFlagValidators(t *testing.T) {
+	f := New()
+	f.DefineOptional("port", "p", "port", "number", "80")
+	f.DefineOptional("name", "n", "name", "name", "")
+	f.DefineOptional("hosts", "H", "hosts", "ips", " 127.0.0.1")
+	f.DefineOptional("mode", "m", "mode", "", "Fast")
+	port, _ := f.GetKey("port")
+	port.SetValidators(IntRange(1, 65535))
+	name, _ := f.GetKey("name")
+	name.SetNormalizers(TrimSpace, Lowercase, func(v string) string { return strings.Trim(v, "_") })
+	name.SetValidators(NonEmpty)
+	hosts, _ := f.GetKey("hosts")
+	hosts.SetSeparator(",")
+	hosts.SetNormalizers(TrimSpace)
+	hosts.SetValidators(ValidIP)
+	mode, _ := f.GetKey("mode")
+	mode.SetNormalizers(Lowercase)
+	mode.SetChoices(false, Choice{Value: "fast"}, Choice{Value: "slow"})
+
+	if err := f.Parse([]string{"--name", "_Alice_", "-H", "10.0.0.1, ::1"}); err != nil {
+		t.Fatal(err)
+	}
+	if name.Value() != "alice" || strings.Join(hosts.Values(), " ") != "10.0.0.1 ::1" {
+		t.Fatalf("normalize failed: %v", f.ParseMap())
+	}
+	if err := f.Parse([]string{"-p", "8080"}); err != nil {
+		t.Fatal(err)
+	}
+	if hosts.Value() != "127.0.0.1" || mode.Value() != "fast" || port.Value() != "8080" {
+		t.Fatalf("default normalize failed: %s %s", hosts.Value(), mode.Value())
+	}
+
+	type Test struct {
+		Args     string
+		Expected error
+	}
+	tests := []Test{
+		{"-p 0", ErrRange},
+		{"-p http", ErrValue},
+		{"--name=__", ErrEmpty},
+		{"-H 10.0.0.1,nope", ErrIP},
+		{"-m SLOWER", ErrChoice},
+	}
+	for _, test := range tests {
+		err := f.Parse(strings.Split(test.Args, " "))
+		var pe *ParseError
+		if !errors.Is(err, test.Expected) || !errors.Is(err, ErrFlagex) || !errors.As(err, &pe) || pe.Flag == nil {
+			t.Fatalf("'%s': expected '%v', got '%v'", test.Args, test.Expected, err)
+		}
+	}
+
+	port.SetDefault("100000")
+	if err := f.Parse([]string{"-n", "x"}); !errors.Is(err, ErrRange) {
+		t.Fatalf("invalid default accepted: %v", err)
+	}
+	if err := f.Parse([]string{"-n", "x", "-p", "1"}); err != nil {
+		t.Fatal(err)
+	}
+}